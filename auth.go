@@ -0,0 +1,127 @@
+package restclient
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Authenticator applies authentication to an outgoing request.  It
+// is applied after the body is encoded and all other headers are
+// set, so implementations may sign the complete request.
+type Authenticator interface {
+	Apply(req *http.Request) error
+}
+
+// BasicAuth authenticates using HTTP Basic authentication
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Apply sets the Authorization header
+func (a BasicAuth) Apply(req *http.Request) error {
+	req.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// BearerAuth authenticates using a bearer token (RFC 6750)
+type BearerAuth struct {
+	Token string
+}
+
+// Apply sets the Authorization header
+func (a BearerAuth) Apply(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+// APIKeyAuth authenticates using an API key sent in a header
+type APIKeyAuth struct {
+	Header string // Header in which to send the key (defaults to "X-API-Key")
+	Value  string
+}
+
+// Apply sets the API key header
+func (a APIKeyAuth) Apply(req *http.Request) error {
+	req.Header.Set(a.header(), a.Value)
+	return nil
+}
+
+func (a APIKeyAuth) header() string {
+	if a.Header == "" {
+		return "X-API-Key"
+	}
+	return a.Header
+}
+
+// HMACSigner authenticates by signing the request with an HMAC over
+// its canonical form, which by default is the method, request URI,
+// timestamp and body, separated by newlines.  The timestamp (Unix
+// seconds) is sent in the TimestampHeader and the hex-encoded
+// signature in the Header.  The body must be rewindable, as it is
+// for all encoded bodies.
+type HMACSigner struct {
+	Secret          []byte                                                        // Shared secret key
+	Hash            func() hash.Hash                                              // Hash function (defaults to SHA-256)
+	Header          string                                                        // Header in which to send the signature (defaults to "X-Signature")
+	TimestampHeader string                                                        // Header in which to send the timestamp (defaults to "X-Timestamp")
+	Canonicalize    func(req *http.Request, body []byte, timestamp string) string // Produces the string to sign (defaults to CanonicalRequest)
+}
+
+// CanonicalRequest is the default canonical form signed by an
+// HMACSigner: the method, request URI, timestamp and body, each
+// separated by a newline
+func CanonicalRequest(req *http.Request, body []byte, timestamp string) string {
+	return req.Method + "\n" + req.URL.RequestURI() + "\n" + timestamp + "\n" + string(body)
+}
+
+// Apply signs the request, setting the timestamp and signature headers
+func (s HMACSigner) Apply(req *http.Request) error {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return fmt.Errorf("Cannot sign a streamed request body")
+		}
+		rc, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		body, err = ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	h := s.Hash
+	if h == nil {
+		h = sha256.New
+	}
+	canonicalize := s.Canonicalize
+	if canonicalize == nil {
+		canonicalize = CanonicalRequest
+	}
+	header := s.Header
+	if header == "" {
+		header = "X-Signature"
+	}
+	timestampHeader := s.TimestampHeader
+	if timestampHeader == "" {
+		timestampHeader = "X-Timestamp"
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(h, s.Secret)
+	mac.Write([]byte(canonicalize(req, body, timestamp)))
+
+	req.Header.Set(timestampHeader, timestamp)
+	req.Header.Set(header, hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
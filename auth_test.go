@@ -0,0 +1,58 @@
+package restclient
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticators(t *testing.T) {
+	assert := assert.New(t)
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer ts.Close()
+
+	req := NewRequest("GET", ts.URL, *auth)
+	req.Authenticator = BearerAuth{"tok"}
+	assert.Nil(req.Do())
+	assert.Equal("Bearer tok", got.Get("Authorization"), "Authenticator should replace Auth")
+
+	req.Authenticator = APIKeyAuth{Value: "key"}
+	assert.Nil(req.Do())
+	assert.Equal("key", got.Get("X-API-Key"))
+	assert.Empty(got.Get("Authorization"))
+
+	req.Authenticator = BasicAuth{"user", "pass"}
+	assert.Nil(req.Do())
+	user, pass, ok := req.Request.BasicAuth()
+	assert.True(ok)
+	assert.Equal("user", user)
+	assert.Equal("pass", pass)
+}
+
+func TestHMACSigner(t *testing.T) {
+	assert := assert.New(t)
+	secret := []byte("secret")
+	var valid bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(CanonicalRequest(r, body, r.Header.Get("X-Timestamp"))))
+		valid = hex.EncodeToString(mac.Sum(nil)) == r.Header.Get("X-Signature")
+	}))
+	defer ts.Close()
+
+	req := NewRequest("POST", ts.URL+"/items?a=1", *auth)
+	req.RequestBody = TestStructRequest{"hi"}
+	req.Authenticator = HMACSigner{Secret: secret}
+	assert.Nil(req.Do())
+	assert.True(valid, "the signature should cover the encoded body")
+}
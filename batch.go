@@ -0,0 +1,56 @@
+package restclient
+
+import (
+	"context"
+	"sync"
+)
+
+// DoBatch executes the given requests through a pool of at most
+// concurrency workers, each request decoding into its own
+// ResponseBody.  It returns the error (or nil) of each request, in
+// the order given.
+//
+// Each request is executed with a child of its own Context which is
+// also bounded by ctx, so cancelling ctx aborts in-flight requests and
+// skips pending ones, while the Context's values are kept.  If
+// failFast is set, the first failure likewise cancels the rest.  Each
+// request's Context is restored once it completes.
+func DoBatch(ctx context.Context, reqs []*Request, concurrency int, failFast bool) []Error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]Error, len(reqs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					errs[i] = transportError(ctx.Err())
+					continue
+				}
+				orig := reqs[i].Context
+				rctx, rcancel := reqs[i].contextWith(ctx)
+				reqs[i].Context = rctx
+				errs[i] = reqs[i].Do()
+				reqs[i].Context = orig
+				rcancel()
+				if errs[i] != nil && failFast {
+					cancel()
+				}
+			}
+		}()
+	}
+	for i := range reqs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return errs
+}
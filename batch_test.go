@@ -0,0 +1,60 @@
+package restclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoBatch(t *testing.T) {
+	assert := assert.New(t)
+	var inflight, maxInflight int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInflight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInflight, m, n) {
+				break
+			}
+		}
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `{"variable":%q}`, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	var reqs []*Request
+	var rets []*TestStructRequest
+	for i := 0; i < 10; i++ {
+		ret := new(TestStructRequest)
+		req := NewRequest("GET", fmt.Sprintf("%s/%d", ts.URL, i), *auth)
+		req.ResponseBody = ret
+		reqs = append(reqs, &req)
+		rets = append(rets, ret)
+	}
+	errs := DoBatch(context.Background(), reqs, 3, false)
+	for i, err := range errs {
+		assert.Nil(err)
+		assert.Equal(fmt.Sprintf("/%d", i), rets[i].Variable)
+	}
+	assert.True(atomic.LoadInt32(&maxInflight) <= 3, "Concurrency should be bounded")
+
+	// With failFast, requests after a failure are skipped
+	fail := NewRequest("GET", ts.URL+"/fail", *auth)
+	reqs = []*Request{&fail}
+	for i := 0; i < 5; i++ {
+		req := NewRequest("GET", ts.URL, *auth)
+		reqs = append(reqs, &req)
+	}
+	errs = DoBatch(context.Background(), reqs, 1, true)
+	assert.NotNil(errs[0])
+	assert.Equal("Canceled", errs[len(errs)-1].Message())
+}
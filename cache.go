@@ -0,0 +1,132 @@
+package restclient
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Cache stores responses so that repeated GET requests may be made
+// conditional on the cached ETag or Last-Modified time.  It may be
+// backed by any store (e.g. Redis); MemoryCache is provided.
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse)
+}
+
+// CachedResponse is a response body stored in a Cache along with
+// its validators
+type CachedResponse struct {
+	Body         []byte
+	ContentType  string
+	ETag         string
+	LastModified string
+}
+
+// MemoryCache is an in-memory Cache, safe for concurrent use
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]*CachedResponse
+}
+
+// NewMemoryCache creates an empty MemoryCache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]*CachedResponse)}
+}
+
+// Get returns the cached response for the key
+func (c *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp, ok := c.entries[key]
+	return resp, ok
+}
+
+// Set stores the response for the key
+func (c *MemoryCache) Set(key string, resp *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = resp
+}
+
+// cacheKey returns the key under which the Request's response is
+// cached, or "" if the request is not cacheable
+func (r *Request) cacheKey() string {
+	if r.Cache == nil || r.Request.Method != "GET" {
+		return ""
+	}
+	return r.Request.Method + " " + r.Request.URL.String()
+}
+
+// setConditionalHeaders makes the request conditional on the
+// validators of the cached response, if any.  Caller-supplied
+// conditional headers take precedence.
+func (r *Request) setConditionalHeaders() {
+	key := r.cacheKey()
+	if key == "" {
+		return
+	}
+	cached, ok := r.Cache.Get(key)
+	if !ok {
+		return
+	}
+	if cached.ETag != "" && r.Request.Header.Get("If-None-Match") == "" {
+		r.Request.Header.Set("If-None-Match", cached.ETag)
+	}
+	if cached.LastModified != "" && r.Request.Header.Get("If-Modified-Since") == "" {
+		r.Request.Header.Set("If-Modified-Since", cached.LastModified)
+	}
+}
+
+// useCachedResponse replaces a 304 Not Modified response with the
+// cached response, which is then processed as a 200 OK
+func (r *Request) useCachedResponse() {
+	key := r.cacheKey()
+	if key == "" || r.Response.StatusCode != http.StatusNotModified {
+		return
+	}
+	cached, ok := r.Cache.Get(key)
+	if !ok {
+		return
+	}
+	r.logger().Println("Not modified; using cached response")
+	io.Copy(ioutil.Discard, r.Response.Body)
+	r.Response.Body.Close()
+
+	r.cached = true
+	r.Response.StatusCode = http.StatusOK
+	r.Response.Status = "200 OK"
+	r.Response.Body = ioutil.NopCloser(bytes.NewReader(cached.Body))
+	if cached.ContentType != "" {
+		r.Response.Header.Set("Content-Type", cached.ContentType)
+	}
+}
+
+// storeResponse stores a successful response carrying a validator
+// in the Cache
+func (r *Request) storeResponse() {
+	key := r.cacheKey()
+	if key == "" || r.Response.StatusCode != http.StatusOK || r.ResponseRaw == nil {
+		return
+	}
+	etag := r.Response.Header.Get("ETag")
+	lastModified := r.Response.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return
+	}
+	r.Cache.Set(key, &CachedResponse{
+		Body:         r.ResponseRaw,
+		ContentType:  r.Response.Header.Get("Content-Type"),
+		ETag:         etag,
+		LastModified: lastModified,
+	})
+}
+
+// FromCache reports whether the response to the last Do was served
+// from the Cache (after the server responded 304 Not Modified)
+// rather than from the network
+func (r *Request) FromCache() bool {
+	return r.cached
+}
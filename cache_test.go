@@ -0,0 +1,66 @@
+package restclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	assert := assert.New(t)
+	var calls, notModified int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"id":1,"name":"one"}`))
+	}))
+	defer ts.Close()
+
+	cache := NewMemoryCache()
+	for i := 0; i < 3; i++ {
+		ret := new(TestThing)
+		req := NewRequest("GET", ts.URL, *auth)
+		req.Cache = cache
+		req.ResponseBody = ret
+		assert.Nil(req.Do())
+		assert.Equal(TestThing{1, "one"}, *ret)
+		assert.Equal(i > 0, req.FromCache())
+	}
+	assert.Equal(3, calls)
+	assert.Equal(2, notModified, "repeated requests should be conditional")
+}
+
+// A cached body is stored decoded, so it must not be transcoded again
+func TestCacheDecodeCharset(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json; charset=ISO-8859-1")
+		// "café" in Latin-1
+		w.Write([]byte("{\"id\":1,\"name\":\"caf\xe9\"}"))
+	}))
+	defer ts.Close()
+
+	cache := NewMemoryCache()
+	for i := 0; i < 2; i++ {
+		ret := new(TestThing)
+		req := NewRequest("GET", ts.URL, *auth)
+		req.Cache = cache
+		req.DecodeCharset = true
+		req.ResponseBody = ret
+		assert.Nil(req.Do())
+		assert.Equal("café", ret.Name)
+		assert.Equal(i > 0, req.FromCache())
+	}
+}
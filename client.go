@@ -0,0 +1,158 @@
+package restclient
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Client produces Requests sharing a common configuration, such
+// as the base URL of the service and its authentication.  Unless a
+// Transport is given, the Client's requests share a transport (and
+// so pooled connections) built from its Timeout, KeepAlive,
+// TransportConfig, DialContext and Proxy when the first request is
+// made; later changes to those fields do not affect it.
+type Client struct {
+	BaseURL string // Base against which request paths are resolved
+	Auth    Auth   // Structure for username and password authentication
+
+	FailoverURLs []string // Further base URLs, tried in order when a request fails with a connection error or 5xx response (see Request.Do)
+
+	Authenticator Authenticator // Authenticator shared by all requests, replacing Auth
+
+	Timeout   time.Duration     // Maximum time to wait for response (defaults to that of NewRequest)
+	KeepAlive time.Duration     // Keep-alive period for connections of the default transport (defaults to 30s)
+	Transport http.RoundTripper // Transport shared by all requests (defaults to DefaultTransport, or else one built from the Client's defaults)
+	Headers   http.Header       // Headers to send with every request
+
+	TransportConfig TransportConfig // Connection pool limits for the default transport
+	Cache           Cache           // Cache shared by all requests
+
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error) // Dials connections for the default transport
+
+	Proxy     string // URL of the forward proxy for the default transport
+	ProxyAuth Auth   // Credentials for the Proxy
+
+	BeforeRequest func(*Request) error // Hook run on every Request just before it is sent
+	Logger        *log.Logger          // Logger for the Client's requests (defaults to the package-level Logger)
+
+	transportOnce sync.Once
+	transport     http.RoundTripper // Transport shared by the Client's requests when none is given
+}
+
+// NewClient creates a new Client for the service at the given
+// base URL.  Note that, per url.URL.ResolveReference, the base
+// URL should end in a slash if its path is to be retained when
+// resolving relative paths.
+func NewClient(baseURL string, auth Auth) *Client {
+	return &Client{BaseURL: baseURL, Auth: auth}
+}
+
+// NewRequest creates a new Request for the given path (or absolute
+// URL), resolved against the Client's base URL and inheriting the
+// Client's defaults
+func (c *Client) NewRequest(method string, path string) Request {
+	req := NewRequest(method, path, c.Auth)
+	req.BaseURL = c.BaseURL
+	req.FailoverURLs = c.FailoverURLs
+	req.Authenticator = c.Authenticator
+	if c.Timeout != 0 {
+		req.Timeout = c.Timeout
+	}
+	req.KeepAlive = c.KeepAlive
+	req.Transport = c.Transport
+	req.TransportConfig = c.TransportConfig
+	req.Cache = c.Cache
+	req.DialContext = c.DialContext
+	req.Proxy = c.Proxy
+	req.ProxyAuth = c.ProxyAuth
+	if c.Headers != nil {
+		req.Headers = c.Headers.Clone()
+	}
+	req.BeforeRequest = c.BeforeRequest
+	req.Logger = c.Logger
+	if req.Transport == nil && DefaultTransport == nil {
+		req.Transport = c.sharedTransport(&req)
+	}
+	return req
+}
+
+// sharedTransport returns the transport shared by the Client's
+// requests, building it on first use from the Client's defaults (as
+// propagated to the given request), so that its connections are
+// pooled according to the TransportConfig
+func (c *Client) sharedTransport(req *Request) http.RoundTripper {
+	c.transportOnce.Do(func() {
+		c.transport = req.newTransport()
+	})
+	return c.transport
+}
+
+// Get is a shorthand MakeRequest with method = "GET"
+func (c *Client) Get(path string, ret interface{}, opts ...Option) Error {
+	r := c.NewRequest("GET", path)
+	r.ResponseBody = ret
+	r.apply(opts)
+	return r.Do()
+}
+
+// GetString is a shorthand MakeRequest with method "GET", returning
+// the response body as a string rather than decoding it
+func (c *Client) GetString(path string, opts ...Option) (string, Error) {
+	r := c.NewRequest("GET", path)
+	r.SkipDecode = true
+	r.apply(opts)
+	if err := r.Do(); err != nil {
+		return "", err
+	}
+	return r.ResponseString(), nil
+}
+
+// Post is a shorthand MakeRequest with method "POST"
+func (c *Client) Post(path string, req interface{}, ret interface{}, opts ...Option) Error {
+	r := c.NewRequest("POST", path)
+	r.RequestBody = req
+	r.ResponseBody = ret
+	r.apply(opts)
+	return r.Do()
+}
+
+// Put is a shorthand MakeRequest with method "PUT"
+func (c *Client) Put(path string, req interface{}, ret interface{}, opts ...Option) Error {
+	r := c.NewRequest("PUT", path)
+	r.RequestBody = req
+	r.ResponseBody = ret
+	r.apply(opts)
+	return r.Do()
+}
+
+// Delete is a shorthand MakeRequest with method "DELETE"
+func (c *Client) Delete(path string, req interface{}, ret interface{}, opts ...Option) Error {
+	r := c.NewRequest("DELETE", path)
+	r.RequestBody = req
+	r.ResponseBody = ret
+	r.apply(opts)
+	return r.Do()
+}
+
+// DeleteQuery is a shorthand MakeRequest with method "DELETE",
+// identifying the resource by query parameters and sending no body
+func (c *Client) DeleteQuery(path string, query map[string]string, ret interface{}, opts ...Option) Error {
+	r := c.NewRequest("DELETE", path)
+	r.QueryParameters = query
+	r.ResponseBody = ret
+	r.apply(opts)
+	return r.Do()
+}
+
+// Patch is a shorthand MakeRequest with method "PATCH"
+func (c *Client) Patch(path string, req interface{}, ret interface{}, opts ...Option) Error {
+	r := c.NewRequest("PATCH", path)
+	r.RequestBody = req
+	r.ResponseBody = ret
+	r.apply(opts)
+	return r.Do()
+}
@@ -0,0 +1,143 @@
+package restclient
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientBaseURL(t *testing.T) {
+	assert := assert.New(t)
+	c := NewClient("http://url.com/api/v1/", *auth)
+	req := c.NewRequest("GET", "items/5")
+	AuthTester(t, *auth, req.Auth)
+	assert.Nil(req.Validate())
+	assert.Nil(req.createHTTPRequest())
+	assert.Equal("http://url.com/api/v1/items/5", req.Request.URL.String())
+
+	req = c.NewRequest("GET", "/status")
+	assert.Nil(req.createHTTPRequest())
+	assert.Equal("http://url.com/status", req.Request.URL.String())
+
+	req = c.NewRequest("GET", "https://other.com/x")
+	assert.Nil(req.createHTTPRequest())
+	assert.Equal("https://other.com/x", req.Request.URL.String())
+}
+
+func TestClientDefaults(t *testing.T) {
+	assert := assert.New(t)
+	var got *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte(`{"variable":"hi"}`))
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL+"/api/", *auth)
+	c.Headers = http.Header{}
+	c.Headers.Set("X-Tenant", "acme")
+
+	ret := TestStructRequest{}
+	assert.Nil(c.Post("items", &TestStructRequest{"in"}, &ret))
+	assert.Equal("POST", got.Method)
+	assert.Equal("/api/items", got.URL.Path)
+	assert.Equal("acme", got.Header.Get("X-Tenant"))
+	user, pass, ok := got.BasicAuth()
+	assert.True(ok)
+	assert.Equal(auth.Username, user)
+	assert.Equal(auth.Password, pass)
+	assert.Equal("hi", ret.Variable)
+}
+
+func TestFinalURL(t *testing.T) {
+	assert := assert.New(t)
+	c := NewClient("http://url.com/api/", *auth)
+	req := c.NewRequest("GET", "items")
+	assert.Equal("", req.FinalURL())
+	req.QueryParameters = map[string]string{"q": "a b"}
+	assert.Nil(req.createHTTPRequest())
+	assert.Equal("http://url.com/api/items?q=a+b", req.FinalURL())
+}
+
+func TestFailover(t *testing.T) {
+	assert := assert.New(t)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	downURL := down.URL + "/"
+	down.Close()
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	var gotBody string
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer up.Close()
+
+	c := NewClient(downURL, *auth)
+	c.FailoverURLs = []string{up.URL + "/"}
+	ret := new(TestThing)
+	assert.Nil(c.Post("things", TestStructRequest{"hi"}, ret))
+	assert.Equal(1, ret.ID)
+	assert.Equal(`{"variable":"hi"}`, gotBody)
+
+	// A 5xx fails over only a request which may safely be repeated
+	c.FailoverURLs = []string{unavailable.URL + "/", up.URL + "/"}
+	gotBody = ""
+	err := c.Post("things", TestStructRequest{"hi"}, ret)
+	assert.NotNil(err)
+	assert.Equal(503, err.Code())
+	assert.Empty(gotBody, "a POST should not be repeated after a 5xx")
+
+	req := c.NewRequest("POST", "things")
+	req.RequestBody = TestStructRequest{"hi"}
+	req.IdempotencyKey = "abc"
+	assert.Nil(req.Do())
+	assert.Equal(`{"variable":"hi"}`, gotBody)
+
+	ret = new(TestThing)
+	assert.Nil(c.Get("things", ret))
+	assert.Equal(1, ret.ID)
+
+	// The last error is returned when every host fails
+	c.FailoverURLs = []string{unavailable.URL + "/"}
+	err = c.Get("things", ret)
+	assert.NotNil(err)
+	assert.Equal(503, err.Code())
+}
+
+func TestClientReusesConnections(t *testing.T) {
+	assert := assert.New(t)
+	var conns int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":1}`))
+	}))
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	c := NewClient(ts.URL+"/", *auth)
+	c.TransportConfig.MaxIdleConnsPerHost = 4
+	for i := 0; i < 3; i++ {
+		ret := new(TestThing)
+		assert.Nil(c.Get("things", ret))
+		assert.Equal(1, ret.ID)
+	}
+	assert.EqualValues(1, atomic.LoadInt32(&conns))
+
+	r1, r2 := c.NewRequest("GET", "a"), c.NewRequest("GET", "b")
+	assert.True(r1.Transport == r2.Transport)
+}
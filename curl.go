@@ -0,0 +1,90 @@
+package restclient
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// redacted replaces credentials in exported commands
+const redacted = "REDACTED"
+
+// AsCurl builds the request (see Build) and renders it as an
+// equivalent curl command line.  Credentials (the basic
+// authentication password, a password in the URL, Authorization-style
+// headers and the API key) are replaced with REDACTED, so the output
+// is safe to share.
+func (r *Request) AsCurl() (string, Error) {
+	req, err := r.Build()
+	if err != nil {
+		return "", err
+	}
+
+	sensitive := map[string]bool{
+		"Authorization":       true,
+		"Proxy-Authorization": true,
+		"Cookie":              true,
+	}
+	if key, ok := r.Authenticator.(APIKeyAuth); ok {
+		sensitive[http.CanonicalHeaderKey(key.header())] = true
+	} else if r.APIKeyValue != "" {
+		sensitive[http.CanonicalHeaderKey(APIKeyAuth{r.APIKeyHeader, r.APIKeyValue}.header())] = true
+	}
+
+	u := *req.URL
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	parts := []string{"curl", "-X", shellQuote(req.Method), shellQuote(u.String())}
+
+	// Render basic authentication as -u rather than as a header
+	if user, _, ok := req.BasicAuth(); ok {
+		parts = append(parts, "-u", shellQuote(user+":"+redacted))
+	}
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k == "Authorization" && strings.HasPrefix(req.Header.Get(k), "Basic ") {
+			continue
+		}
+		for _, v := range req.Header[k] {
+			if sensitive[k] {
+				v = redacted
+			}
+			parts = append(parts, "-H", shellQuote(k+": "+v))
+		}
+	}
+
+	if req.GetBody != nil {
+		body, gerr := req.GetBody()
+		if gerr != nil {
+			return "", BaseError{0, "Error", gerr}
+		}
+		b, rerr := ioutil.ReadAll(body)
+		body.Close()
+		if rerr != nil {
+			return "", BaseError{0, "Error", rerr}
+		}
+		if len(b) > 0 {
+			if r.CompressRequest {
+				parts = append(parts, "--data-binary", "@-", fmt.Sprintf("# (%d bytes of gzip-compressed body omitted)", len(b)))
+			} else {
+				parts = append(parts, "--data-binary", shellQuote(string(b)))
+			}
+		}
+	}
+
+	return strings.Join(parts, " "), nil
+}
+
+// shellQuote quotes a string for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
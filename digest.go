@@ -0,0 +1,191 @@
+package restclient
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// digestChallenge holds the parameters of a WWW-Authenticate: Digest challenge
+type digestChallenge struct {
+	Realm     string
+	Nonce     string
+	Qop       string
+	Opaque    string
+	Algorithm string
+}
+
+// parseDigestChallenge parses the value of a WWW-Authenticate header
+// containing a Digest challenge
+func parseDigestChallenge(header string) (digestChallenge, error) {
+	var c digestChallenge
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Digest ") {
+		return c, fmt.Errorf("Not a Digest challenge: %q", header)
+	}
+
+	for _, param := range splitDigestParams(header[7:]) {
+		index := strings.Index(param, "=")
+		if index == -1 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(param[:index]))
+		val := strings.Trim(strings.TrimSpace(param[index+1:]), `"`)
+		switch key {
+		case "realm":
+			c.Realm = val
+		case "nonce":
+			c.Nonce = val
+		case "qop":
+			c.Qop = val
+		case "opaque":
+			c.Opaque = val
+		case "algorithm":
+			c.Algorithm = val
+		}
+	}
+	if c.Nonce == "" {
+		return c, fmt.Errorf("Digest challenge is missing a nonce")
+	}
+	return c, nil
+}
+
+// splitDigestParams splits a comma-separated list of parameters,
+// ignoring commas inside quoted strings
+func splitDigestParams(s string) []string {
+	var params []string
+	var quoted bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				params = append(params, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(params, s[start:])
+}
+
+// authorization computes the Authorization header value answering
+// the challenge for the given method and request URI, using the
+// given client nonce
+func (c digestChallenge) authorization(auth Auth, method, uri, cnonce string) (string, error) {
+	var h func() hash.Hash
+	switch strings.ToUpper(c.Algorithm) {
+	case "", "MD5", "MD5-SESS":
+		h = md5.New
+	case "SHA-256", "SHA-256-SESS":
+		h = sha256.New
+	default:
+		return "", fmt.Errorf("Unsupported Digest algorithm: %s", c.Algorithm)
+	}
+	hashHex := func(s string) string {
+		d := h()
+		io.WriteString(d, s)
+		return hex.EncodeToString(d.Sum(nil))
+	}
+
+	nc := "00000001"
+
+	ha1 := hashHex(auth.Username + ":" + c.Realm + ":" + auth.Password)
+	if strings.HasSuffix(strings.ToUpper(c.Algorithm), "-SESS") {
+		ha1 = hashHex(ha1 + ":" + c.Nonce + ":" + cnonce)
+	}
+	ha2 := hashHex(method + ":" + uri)
+
+	var qop string
+	for _, q := range strings.Split(c.Qop, ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	var response string
+	if qop == "" {
+		response = hashHex(ha1 + ":" + c.Nonce + ":" + ha2)
+	} else {
+		response = hashHex(ha1 + ":" + c.Nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2)
+	}
+
+	out := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		auth.Username, c.Realm, c.Nonce, uri, response)
+	if c.Algorithm != "" {
+		out += fmt.Sprintf(`, algorithm=%s`, c.Algorithm)
+	}
+	if qop != "" {
+		out += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, nc, cnonce)
+	}
+	if c.Opaque != "" {
+		out += fmt.Sprintf(`, opaque="%s"`, c.Opaque)
+	}
+	return out, nil
+}
+
+// digestRetry answers the Digest challenge in the current (401)
+// Response and resends the request with the computed Authorization
+func (r *Request) digestRetry() error {
+	r.logger().Println("digestRetry: started")
+
+	// Discard the challenge response
+	io.Copy(ioutil.Discard, r.Response.Body)
+	r.Response.Body.Close()
+
+	c, err := parseDigestChallenge(r.Response.Header.Get("WWW-Authenticate"))
+	if err != nil {
+		return err
+	}
+	cnonce, err := randomHex(8)
+	if err != nil {
+		return err
+	}
+	authorization, err := c.authorization(r.Auth, r.Request.Method, r.Request.URL.RequestURI(), cnonce)
+	if err != nil {
+		return err
+	}
+	r.Request.Header.Set("Authorization", authorization)
+
+	// Rewind the body for the second attempt
+	if r.Request.GetBody != nil {
+		r.Request.Body, err = r.Request.GetBody()
+		if err != nil {
+			return err
+		}
+	}
+
+	r.Response, err = r.Client.Do(r.Request)
+	if err != nil {
+		return err
+	}
+
+	r.logger().Println("digestRetry: completed")
+	return nil
+}
+
+// randomHex returns n random bytes, hex-encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// isDigestChallenge reports whether the response is a 401 carrying
+// a Digest challenge
+func isDigestChallenge(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	h := resp.Header.Get("WWW-Authenticate")
+	return len(h) >= 7 && strings.EqualFold(h[:7], "Digest ")
+}
@@ -0,0 +1,114 @@
+package restclient
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// decodeContent wraps the body to undo the given Content-Encoding,
+// which may list several codings in the order they were applied.
+// The gzip and deflate codings are supported; brotli ("br") is not,
+// to avoid a dependency, so it should not be advertised in an
+// Accept-Encoding header.
+func decodeContent(body io.Reader, contentEncoding string) (io.Reader, error) {
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		switch coding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			zr, err := gzip.NewReader(body)
+			if err == io.EOF {
+				// An empty body, as for many error responses
+				return body, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			body = zr
+		case "deflate":
+			body = deflateReader(body)
+		default:
+			return nil, fmt.Errorf("Unsupported Content-Encoding: %s", coding)
+		}
+	}
+	return body, nil
+}
+
+// decodeResponseContent wraps the response body to undo its
+// Content-Encoding.  With SniffGzip, a body without a
+// Content-Encoding is also decompressed if it starts with the gzip
+// magic number.  With DecodeCharset, the body is then transcoded to
+// UTF-8.  A body served from the Cache was stored already decoded, and
+// is returned unchanged.
+func (r *Request) decodeResponseContent(body io.Reader) (io.Reader, error) {
+	if r.cached {
+		return body, nil
+	}
+	contentEncoding := r.Response.Header.Get("Content-Encoding")
+	var err error
+	if r.SniffGzip && contentEncoding == "" {
+		body, err = sniffGzip(body)
+	} else {
+		body, err = decodeContent(body, contentEncoding)
+	}
+	if err != nil || !r.DecodeCharset {
+		return body, err
+	}
+	return decodeCharset(body, r.Response.Header.Get("Content-Type"))
+}
+
+// decodeCharset transcodes the body to UTF-8 from the charset given
+// in the Content-Type, if any.  Charsets are named as in the WHATWG
+// Encoding Standard, which (as browsers do) reads ISO-8859-1 as
+// windows-1252.
+func decodeCharset(body io.Reader, contentType string) (io.Reader, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return body, nil
+	}
+	charset := strings.ToLower(params["charset"])
+	switch charset {
+	case "", "utf-8", "utf8", "us-ascii":
+		return body, nil
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("Unsupported charset: %s", charset)
+	}
+	return transform.NewReader(body, enc.NewDecoder()), nil
+}
+
+// sniffGzip decompresses the body if it begins with the gzip magic
+// number (0x1f 0x8b), and otherwise returns it unchanged
+func sniffGzip(body io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(body)
+	magic, err := br.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return br, nil
+	}
+	return gzip.NewReader(br)
+}
+
+// deflateReader reads a deflate-encoded body, which should be
+// zlib-wrapped but which some servers send as raw deflate
+func deflateReader(body io.Reader) io.Reader {
+	br := bufio.NewReader(body)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		if zr, err := zlib.NewReader(br); err == nil {
+			return zr
+		}
+	}
+	return flate.NewReader(br)
+}
@@ -0,0 +1,189 @@
+package restclient
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeContent(t *testing.T) {
+	assert := assert.New(t)
+	const body = `{"id":1,"name":"one"}`
+
+	var gz, zl, raw bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(body))
+	zw.Close()
+	lw := zlib.NewWriter(&zl)
+	lw.Write([]byte(body))
+	lw.Close()
+	fw, _ := flate.NewWriter(&raw, flate.DefaultCompression)
+	fw.Write([]byte(body))
+	fw.Close()
+
+	for encoding, encoded := range map[string][]byte{
+		"":         []byte(body),
+		"identity": []byte(body),
+		"gzip":     gz.Bytes(),
+		"deflate":  zl.Bytes(),
+		" Deflate": raw.Bytes(),
+	} {
+		r, err := decodeContent(bytes.NewReader(encoded), encoding)
+		assert.Nil(err, encoding)
+		b, err := io.ReadAll(r)
+		assert.Nil(err, encoding)
+		assert.Equal(body, string(b), encoding)
+	}
+
+	// Codings are undone in reverse order
+	var double bytes.Buffer
+	zw = gzip.NewWriter(&double)
+	zw.Write(zl.Bytes())
+	zw.Close()
+	r, err := decodeContent(bytes.NewReader(double.Bytes()), "deflate, gzip")
+	assert.Nil(err)
+	b, _ := io.ReadAll(r)
+	assert.Equal(body, string(b))
+
+	_, err = decodeContent(strings.NewReader(body), "br")
+	assert.NotNil(err)
+}
+
+func TestGzipResponse(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(`{"id":1,"name":"one"}`))
+		zw.Close()
+	}))
+	defer ts.Close()
+
+	// Advertising encodings explicitly disables the transport's
+	// transparent decompression
+	ret := new(TestThing)
+	req := NewRequest("GET", ts.URL, *auth)
+	req.SetHeader("Accept-Encoding", "gzip, deflate")
+	req.ResponseBody = ret
+	assert.Nil(req.Do())
+	assert.Equal(TestThing{1, "one"}, *ret)
+}
+
+func TestSniffGzip(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/plain" {
+			w.Write([]byte(`{"id":2,"name":"two"}`))
+			return
+		}
+		// Gzipped without a Content-Encoding header
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(`{"id":1,"name":"one"}`))
+		zw.Close()
+	}))
+	defer ts.Close()
+
+	ret := new(TestThing)
+	req := NewRequest("GET", ts.URL, *auth)
+	req.ResponseBody = ret
+	assert.NotNil(req.Do())
+
+	req = NewRequest("GET", ts.URL, *auth)
+	req.SniffGzip = true
+	req.ResponseBody = ret
+	assert.Nil(req.Do())
+	assert.Equal(TestThing{1, "one"}, *ret)
+
+	// Uncompressed bodies are unaffected
+	req = NewRequest("GET", ts.URL+"/plain", *auth)
+	req.SniffGzip = true
+	req.ResponseBody = ret
+	assert.Nil(req.Do())
+	assert.Equal(TestThing{2, "two"}, *ret)
+}
+
+func TestDecodeCharset(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("iso-8859-1", r.Header.Get("Accept-Charset"))
+		w.Header().Set("Content-Type", "application/json; charset=ISO-8859-1")
+		// "Müller" in Latin-1
+		w.Write([]byte("{\"id\":1,\"name\":\"M\xfcller\"}"))
+	}))
+	defer ts.Close()
+
+	ret := new(TestThing)
+	req := NewRequest("GET", ts.URL, *auth)
+	req.AcceptCharset("iso-8859-1")
+	req.DecodeCharset = true
+	req.ResponseBody = ret
+	assert.Nil(req.Do())
+	assert.Equal("Müller", ret.Name)
+
+	// Without DecodeCharset the bytes are decoded as UTF-8
+	req = NewRequest("GET", ts.URL, *auth)
+	req.AcceptCharset("iso-8859-1")
+	req.ResponseBody = ret
+	assert.Nil(req.Do())
+	assert.NotEqual("Müller", ret.Name)
+
+	_, err := decodeCharset(strings.NewReader(""), "text/plain; charset=x-unknown")
+	assert.NotNil(err)
+}
+
+func TestAcceptEncodings(t *testing.T) {
+	assert := assert.New(t)
+	var accept []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header["Accept-Encoding"]
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			zw.Write([]byte(`{"id":1,"name":"one"}`))
+			zw.Close()
+			return
+		}
+		w.Write([]byte(`{"id":2,"name":"two"}`))
+	}))
+	defer ts.Close()
+
+	// By default, Go advertises and transparently decodes gzip
+	ret := new(TestThing)
+	req := NewRequest("GET", ts.URL, *auth)
+	req.ResponseBody = ret
+	assert.Nil(req.Do())
+	assert.Equal([]string{"gzip"}, accept)
+	assert.Equal(TestThing{1, "one"}, *ret)
+
+	req = NewRequest("GET", ts.URL, *auth)
+	req.AcceptEncodings = []string{"gzip", "deflate"}
+	req.ResponseBody = ret
+	assert.Nil(req.Do())
+	assert.Equal([]string{"gzip, deflate"}, accept)
+	assert.Equal(TestThing{1, "one"}, *ret)
+
+	req = NewRequest("GET", ts.URL, *auth)
+	req.AcceptEncodings = []string{}
+	req.ResponseBody = ret
+	assert.Nil(req.Do())
+	assert.Nil(accept)
+	assert.Equal(TestThing{2, "two"}, *ret)
+	assert.NotNil(req.Clone().AcceptEncodings)
+
+	// A shared transport cannot disable compression, so identity is requested
+	req = NewRequest("GET", ts.URL, *auth)
+	req.Transport = &http.Transport{}
+	req.AcceptEncodings = []string{}
+	req.ResponseBody = ret
+	assert.Nil(req.Do())
+	assert.Equal([]string{"identity"}, accept)
+}
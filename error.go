@@ -1,5 +1,13 @@
 package restclient
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
 type Error interface {
 	Error() string
 	Code() int
@@ -23,3 +31,205 @@ func (e BaseError) Code() int {
 func (e BaseError) Message() string {
 	return e.Status
 }
+
+// Unwrap returns the underlying error
+func (e BaseError) Unwrap() error {
+	return e.Err
+}
+
+// ResponseTooLargeError is returned when a response body exceeds
+// the Request's MaxResponseBytes
+type ResponseTooLargeError struct {
+	BaseError
+	Limit int64 // The limit which was exceeded
+}
+
+// EncodeError is returned when the request body could not be encoded
+type EncodeError struct {
+	BaseError
+	Method      string // Method of the failed request
+	Url         string // URL of the failed request
+	RequestType string // Encoding which was attempted
+}
+
+func (e EncodeError) Error() string {
+	return fmt.Sprintf("Failed to encode %s body for %s %s: %v", e.RequestType, e.Method, e.Url, e.Err)
+}
+
+// DecodeError is returned when the response body could not be
+// decoded into the ResponseBody
+type DecodeError struct {
+	BaseError
+	ContentType string // Content-Type of the response
+	Body        []byte // The start of the response body, up to maxDecodeErrorBody bytes
+	Target      string // Go type of the ResponseBody
+}
+
+func (e DecodeError) Error() string {
+	return fmt.Sprintf("Failed to decode %s response into %s: %v (body: %q)", e.ContentType, e.Target, e.Err, e.Body)
+}
+
+// maxDecodeErrorBody is the number of bytes of the response body
+// included in a DecodeError
+const maxDecodeErrorBody = 256
+
+// EmptyResponseError is returned when RequireBody is set and a
+// successful response which should be decoded has an empty body
+type EmptyResponseError struct {
+	BaseError
+}
+
+// TimeoutError is returned when the request timed out, either
+// because a deadline was exceeded or the network operation timed out
+type TimeoutError struct {
+	BaseError
+}
+
+// CanceledError is returned when the request's context was canceled
+type CanceledError struct {
+	BaseError
+}
+
+// ConnectionError is returned when the connection to the server
+// could not be established or was lost
+type ConnectionError struct {
+	BaseError
+}
+
+// transportError classifies an error returned by the transport
+func transportError(err error) Error {
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return CanceledError{BaseError{0, "Canceled", err}}
+	case errors.Is(err, context.DeadlineExceeded):
+		return TimeoutError{BaseError{0, "Timeout", err}}
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return TimeoutError{BaseError{0, "Timeout", err}}
+	case errors.As(err, &opErr):
+		return ConnectionError{BaseError{0, "Connection Error", err}}
+	}
+	return BaseError{0, "Unknown Error", err}
+}
+
+// SwitchingProtocolsError is returned when the server responds with
+// 101 Switching Protocols (e.g. a WebSocket upgrade), which this
+// package does not speak.  The upgraded connection is handed to the
+// caller, who is responsible for closing it.
+type SwitchingProtocolsError struct {
+	BaseError
+	Protocol string             // Protocol from the Upgrade header
+	Conn     io.ReadWriteCloser // The upgraded connection, if available
+}
+
+// errorBody holds the ErrorBody decoded from an error response, for
+// embedding in the errors of the statuses which may carry one
+type errorBody struct {
+	Body interface{} // The decoded ErrorBody, if any
+}
+
+// Parsed returns the Request's ErrorBody, decoded from the response,
+// or nil if no ErrorBody was set or it could not be decoded
+func (e errorBody) Parsed() interface{} {
+	return e.Body
+}
+
+// UnauthorizedError is returned for a 401 Unauthorized response,
+// usually meaning the credentials are missing, invalid or expired
+type UnauthorizedError struct {
+	BaseError
+	errorBody
+}
+
+// ForbiddenError is returned for a 403 Forbidden response, meaning
+// the credentials lack permission for the request
+type ForbiddenError struct {
+	BaseError
+	errorBody
+}
+
+// PreconditionFailedError is returned for a 412 Precondition Failed
+// response, meaning a conditional request (e.g. If-Match or
+// If-Unmodified-Since) lost to a concurrent update; the resource
+// should be re-fetched
+type PreconditionFailedError struct {
+	BaseError
+	errorBody
+}
+
+// UnsupportedMediaTypeError is returned for a 415 Unsupported Media
+// Type response, meaning the server does not accept the body's
+// Content-Type or Content-Encoding (such as a compressed body)
+type UnsupportedMediaTypeError struct {
+	BaseError
+	errorBody
+	ContentType     string // Content-Type of the rejected body
+	ContentEncoding string // Content-Encoding of the rejected body, if any
+}
+
+// RequestError is returned for a 4xx response not covered by a
+// more specific error
+type RequestError struct {
+	BaseError
+	errorBody
+}
+
+// ServerError is returned for a 5xx response
+type ServerError struct {
+	BaseError
+	errorBody
+}
+
+// ProblemError is returned for a 4xx or 5xx response carrying an RFC
+// 7807 problem document (Content-Type: application/problem+json), in
+// place of any other error for the status (such as a RequestError,
+// ServerError or UnauthorizedError)
+type ProblemError struct {
+	BaseError
+	errorBody
+
+	problem problemDocument
+}
+
+// problemDocument holds the standard members of an RFC 7807 problem
+// document
+type problemDocument struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance"`
+}
+
+// Type returns the URI reference identifying the problem type,
+// which defaults to "about:blank"
+func (e ProblemError) Type() string {
+	if e.problem.Type == "" {
+		return "about:blank"
+	}
+	return e.problem.Type
+}
+
+// Title returns the short, human-readable summary of the problem type
+func (e ProblemError) Title() string {
+	return e.problem.Title
+}
+
+// ProblemStatus returns the status code given in the problem
+// document, or 0 if it had none (see Code for that of the response)
+func (e ProblemError) ProblemStatus() int {
+	return e.problem.Status
+}
+
+// Detail returns the human-readable explanation of this occurrence
+// of the problem
+func (e ProblemError) Detail() string {
+	return e.problem.Detail
+}
+
+// Instance returns the URI reference identifying this occurrence of
+// the problem
+func (e ProblemError) Instance() string {
+	return e.problem.Instance
+}
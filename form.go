@@ -1,22 +1,42 @@
 package restclient
 
 import (
+	"encoding"
+	"fmt"
 	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type tagOptions string
 
+// formTagKeys lists the struct tags consulted, in order of preference,
+// when naming form-encoded fields
+var formTagKeys = []string{"form", "json"}
+
+// queryTagKeys lists the struct tags consulted, in order of preference,
+// when naming query string parameters
+var queryTagKeys = []string{"query", "form", "json"}
+
+// tagKeys returns the struct tags naming form fields: the
+// FormTagKey alone, if set, or the default formTagKeys
+func (r *Request) tagKeys() []string {
+	if r.FormTagKey != "" {
+		return []string{r.FormTagKey}
+	}
+	return formTagKeys
+}
+
 // encodeForm encodes the request body to url.Values.Encode()
 func (r *Request) encodeForm() ([]byte, error) {
 	var out []byte
-	Logger.Printf("Encoding bodyObject (%+v) to url.Values form\n", r.RequestBody)
+	r.logger().Printf("Encoding bodyObject (%+v) to url.Values form\n", r.RequestBody)
 
-	v, err := structToVals(r.RequestBody)
+	v, err := structToVals(r.RequestBody, r.tagKeys(), r.logger())
 	if err != nil {
-		Logger.Println("Failed to convert struct to url.Values:", err.Error())
+		r.logger().Println("Failed to convert struct to url.Values:", err.Error())
 		return out, err
 	}
 
@@ -26,81 +46,331 @@ func (r *Request) encodeForm() ([]byte, error) {
 	return out, nil
 }
 
-// Convert a struct to an url.Values map
-func structToVals(s interface{}) (url.Values, error) {
+// Convert a struct to an url.Values map, naming fields by the first
+// of the given tag keys present on each field.  Maps of strings
+// (including url.Values) are converted directly.  Fields of unhandled
+// types are skipped, and logged to the logger.
+func structToVals(s interface{}, tagKeys []string, logger callLogger) (url.Values, error) {
+	// Maps are encoded directly
+	switch m := s.(type) {
+	case url.Values:
+		return m, nil
+	case map[string][]string:
+		return url.Values(m), nil
+	case map[string]string:
+		v := make(url.Values, len(m))
+		for k, val := range m {
+			v.Set(k, val)
+		}
+		return v, nil
+	}
+
 	v := url.Values{}
-	structVals := reflect.ValueOf(s).Elem()
+	structVals := reflect.ValueOf(s)
+	if structVals.Kind() == reflect.Ptr {
+		if structVals.IsNil() {
+			return v, fmt.Errorf("Cannot encode nil %T", s)
+		}
+		structVals = structVals.Elem()
+	}
+	if structVals.Kind() != reflect.Struct {
+		return v, fmt.Errorf("Cannot encode %T: must be a struct, a pointer to a struct or a map of strings", s)
+	}
+	if err := addStructVals(v, structVals, tagKeys, logger); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+// addStructVals adds the fields of the struct to the url.Values.
+// Fields of anonymous (embedded) structs are promoted, as with
+// encoding/json, without overriding fields of the outer struct.  It
+// returns the error of any field failing to marshal.
+func addStructVals(v url.Values, structVals reflect.Value, tagKeys []string, logger callLogger) error {
 	t := structVals.Type()
+	var embedded []reflect.Value
 	for i := 0; i < structVals.NumField(); i++ {
+		field := t.Field(i)
 		f := structVals.Field(i)
-		var val string
-		switch f.Interface().(type) {
-		case int, int8, int16, int32, int64:
-			val = strconv.FormatInt(f.Int(), 10)
-		case uint, uint8, uint16, uint32, uint64:
-			val = strconv.FormatUint(f.Uint(), 10)
-		case float32:
-			val = strconv.FormatFloat(f.Float(), 'f', 4, 32)
-		case float64:
-			val = strconv.FormatFloat(f.Float(), 'f', 4, 64)
-		case []byte:
-			val = string(f.Bytes())
-		case string:
-			val = f.String()
-		default:
-			Logger.Println("Ignoring unhandled type")
+		if field.Anonymous && !hasTag(field, tagKeys) {
+			// Promote fields of untagged embedded structs
+			if f.Kind() == reflect.Ptr && f.Type().Elem().Kind() == reflect.Struct {
+				if !f.IsNil() {
+					embedded = append(embedded, f.Elem())
+				}
+				continue
+			}
+			if f.Kind() == reflect.Struct {
+				embedded = append(embedded, f)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			// Ignore unexported fields
 			continue
 		}
-		name, opts := getTagName(t.Field(i))
+		name, opts := getTagName(field, tagKeys)
 		if name == "" {
 			// If we have no name, ignore this field
 			continue
 		}
 		// Check for omitempty
-		if val == "" && opts.Contains("omitempty") {
-			// If we have no value and we have omitempty set, ignore this field
+		if opts.Contains("omitempty") && isEmptyValue(f) {
+			// If we have an empty value and we have omitempty set, ignore this field
 			continue
 		}
 
+		if opts.Contains("utc") {
+			f = toUTC(f)
+		}
+		val, ok, err := formatValue(f)
+		if err != nil {
+			return fmt.Errorf("Failed to encode field %s: %v", field.Name, err)
+		}
+		if !ok {
+			logger.Printf("Ignoring field %s of unhandled type %s\n", field.Name, f.Type())
+			continue
+		}
 		v.Set(name, val)
 	}
-	return v, nil
+
+	for _, e := range embedded {
+		promoted := url.Values{}
+		if err := addStructVals(promoted, e, tagKeys, logger); err != nil {
+			return err
+		}
+		for k, vals := range promoted {
+			if _, ok := v[k]; !ok {
+				v[k] = vals
+			}
+		}
+	}
+	return nil
 }
 
-// getTagName returns the name from the tag and a list of
-// options (such as omitempty)
-func getTagName(f reflect.StructField) (string, tagOptions) {
-	var name string
-	var opts tagOptions
-
-	// Check for the 'form' tag preferentially
-	tagText := f.Tag.Get("form")
-	if tagText != "" {
-		// Explicit ignore
-		if tagText == "-" {
-			return "", ""
+// formatValue formats a field value as a string for form encoding,
+// dereferencing pointers.  A nil pointer is formatted as the empty
+// string.  Types implementing encoding.TextMarshaler or fmt.Stringer
+// are formatted by those methods; notably, a time.Time is formatted
+// as RFC 3339 in its own location, or in UTC if the field is tagged
+// with the "utc" option (e.g. `form:"ts,utc"`).  It returns false if the type is
+// not handled, and the error of a failing MarshalText.  The value is
+// otherwise inspected by kind, so that fields promoted from unexported
+// embedded structs may be formatted.
+func formatValue(f reflect.Value) (string, bool, error) {
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return "", true, nil
 		}
-		// Extract options
-		name = tagText
-		if index := strings.Index(tagText, ","); index != -1 {
-			name = tagText[:index]
-			opts = tagOptions(tagText[index+1:])
+		f = f.Elem()
+	}
+	if s, ok, err := marshalText(f); ok || err != nil {
+		return s, ok, err
+	}
+	if f.Type().PkgPath() != "" {
+		// Only predeclared types are handled
+		return "", false, nil
+	}
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(f.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(f.Uint(), 10), true, nil
+	case reflect.Float32:
+		return strconv.FormatFloat(f.Float(), 'f', 4, 32), true, nil
+	case reflect.Float64:
+		return strconv.FormatFloat(f.Float(), 'f', 4, 64), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(f.Bool()), true, nil
+	case reflect.String:
+		return f.String(), true, nil
+	case reflect.Slice:
+		if f.Type().Elem().Kind() == reflect.Uint8 {
+			return string(f.Bytes()), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// toUTC converts a time.Time (or *time.Time) value to UTC, for
+// fields tagged with the "utc" option.  Other values are returned
+// unchanged.
+func toUTC(f reflect.Value) reflect.Value {
+	if f.Kind() == reflect.Ptr && !f.IsNil() {
+		f = f.Elem()
+	}
+	if !f.CanInterface() {
+		return f
+	}
+	if t, ok := f.Interface().(time.Time); ok {
+		return reflect.ValueOf(t.UTC())
+	}
+	return f
+}
+
+// marshalText formats the value using its MarshalText or String
+// method, if it (or a pointer to it) has one, returning the error of a
+// failing MarshalText
+func marshalText(f reflect.Value) (string, bool, error) {
+	if !f.CanInterface() {
+		return "", false, nil
+	}
+	candidates := []interface{}{f.Interface()}
+	if f.CanAddr() {
+		candidates = append(candidates, f.Addr().Interface())
+	}
+	for _, c := range candidates {
+		if m, ok := c.(encoding.TextMarshaler); ok {
+			text, err := m.MarshalText()
+			if err != nil {
+				return "", false, err
+			}
+			return string(text), true, nil
+		}
+	}
+	for _, c := range candidates {
+		if m, ok := c.(fmt.Stringer); ok {
+			return m.String(), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// isEmptyValue reports whether the value is empty in the sense
+// of encoding/json's omitempty: false, 0, a nil pointer or
+// interface, or an empty array, map, slice or string
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// decodeForm decodes a urlencoded response body into out
+func (r *Request) decodeForm(body []byte, out interface{}) error {
+	r.logger().Println("Decoding url.Values form into response body")
+
+	v, err := url.ParseQuery(string(body))
+	if err != nil {
+		r.logger().Println("Failed to parse form:", err.Error())
+		return err
+	}
+
+	// Maps receive the values directly
+	switch body := out.(type) {
+	case *url.Values:
+		*body = v
+		return nil
+	case *map[string]string:
+		if *body == nil {
+			*body = make(map[string]string, len(v))
+		}
+		for k := range v {
+			(*body)[k] = v.Get(k)
+		}
+		return nil
+	}
+	return valsToStruct(v, out, r.tagKeys(), r.logger())
+}
+
+// Populate a struct from an url.Values map, the inverse of structToVals
+func valsToStruct(v url.Values, s interface{}, tagKeys []string, logger callLogger) error {
+	ptr := reflect.ValueOf(s)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Cannot decode form into %T: must be a pointer to a struct", s)
+	}
+	structVals := ptr.Elem()
+	t := structVals.Type()
+	for i := 0; i < structVals.NumField(); i++ {
+		f := structVals.Field(i)
+		name, _ := getTagName(t.Field(i), tagKeys)
+		if name == "" || !f.CanSet() {
+			continue
+		}
+		if _, ok := v[name]; !ok {
+			continue
+		}
+		val := v.Get(name)
+		switch f.Interface().(type) {
+		case int, int8, int16, int32, int64:
+			n, err := strconv.ParseInt(val, 10, f.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("Failed to parse %s: %v", name, err)
+			}
+			f.SetInt(n)
+		case uint, uint8, uint16, uint32, uint64:
+			n, err := strconv.ParseUint(val, 10, f.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("Failed to parse %s: %v", name, err)
+			}
+			f.SetUint(n)
+		case float32, float64:
+			n, err := strconv.ParseFloat(val, f.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("Failed to parse %s: %v", name, err)
+			}
+			f.SetFloat(n)
+		case bool:
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return fmt.Errorf("Failed to parse %s: %v", name, err)
+			}
+			f.SetBool(b)
+		case []byte:
+			f.SetBytes([]byte(val))
+		case string:
+			f.SetString(val)
+		default:
+			logger.Printf("Ignoring field %s of unhandled type %s\n", t.Field(i).Name, f.Type())
+		}
+	}
+	return nil
+}
+
+// hasTag reports whether the field carries any of the tag keys
+func hasTag(f reflect.StructField, tagKeys []string) bool {
+	for _, key := range tagKeys {
+		if _, ok := f.Tag.Lookup(key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// getTagName returns the name from the tag and a list of
+// options (such as omitempty).  The tag keys are checked in
+// the order given, falling back to the field name.
+func getTagName(f reflect.StructField, tagKeys []string) (string, tagOptions) {
+	for _, key := range tagKeys {
+		tagText := f.Tag.Get(key)
+		if tagText == "" {
+			continue
 		}
-	} else if tagText = f.Tag.Get("json"); tagText != "" {
 		// Explicit ignore
 		if tagText == "-" {
 			return "", ""
 		}
 		// Extract options
-		name = tagText
+		name := tagText
+		var opts tagOptions
 		if index := strings.Index(tagText, ","); index != -1 {
 			name = tagText[:index]
 			opts = tagOptions(tagText[index+1:])
 		}
-	} else {
-		name = f.Name
+		return name, opts
 	}
-	return name, opts
+	return f.Name, ""
 }
 
 func (o tagOptions) Contains(optionName string) bool {
@@ -0,0 +1,187 @@
+package restclient
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type TestOmitEmpty struct {
+	Count   int     `json:"count,omitempty"`
+	Enabled bool    `json:"enabled,omitempty"`
+	Name    string  `json:"name,omitempty"`
+	Ratio   float64 `json:"ratio,omitempty"`
+	Ref     *int    `json:"ref,omitempty"`
+	Kept    int     `json:"kept"`
+}
+
+func TestStructToValsOmitEmpty(t *testing.T) {
+	assert := assert.New(t)
+	v, err := structToVals(&TestOmitEmpty{}, formTagKeys, callLogger{})
+	assert.Nil(err)
+	assert.Equal(1, len(v), "Only the field without omitempty should be encoded")
+	assert.Equal("0", v.Get("kept"))
+
+	ref := 0
+	v, err = structToVals(&TestOmitEmpty{Count: 3, Enabled: true, Name: "x", Ref: &ref}, formTagKeys, callLogger{})
+	assert.Nil(err)
+	assert.Equal("3", v.Get("count"))
+	assert.Equal("true", v.Get("enabled"))
+	assert.Equal("x", v.Get("name"))
+	assert.Equal("0", v.Get("ref"), "Non-nil pointers should be dereferenced")
+}
+
+func TestEncodeFormMap(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequest("POST", "http://url.com", *auth)
+	req.RequestType = "form"
+	req.RequestBody = map[string]string{"b": "2", "a": "1 2"}
+	body, err := req.encodeForm()
+	assert.Nil(err)
+	assert.Equal("a=1+2&b=2", string(body))
+
+	req.RequestBody = url.Values{"x": {"1", "2"}}
+	body, err = req.encodeForm()
+	assert.Nil(err)
+	assert.Equal("x=1&x=2", string(body))
+}
+
+func TestEncodeFormTagKey(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequest("POST", "http://url.com", *auth)
+	req.RequestType = "form"
+	req.RequestBody = struct {
+		UserName string `form:"user" json:"user_name" api:"username"`
+		Plain    int
+	}{"bob", 1}
+	body, err := req.encodeForm()
+	assert.Nil(err)
+	assert.Equal("Plain=1&user=bob", string(body))
+
+	req.FormTagKey = "json"
+	body, err = req.encodeForm()
+	assert.Nil(err)
+	assert.Equal("Plain=1&user_name=bob", string(body))
+
+	req.FormTagKey = "api"
+	body, err = req.encodeForm()
+	assert.Nil(err)
+	assert.Equal("Plain=1&username=bob", string(body))
+}
+
+func TestStructToValsInvalid(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequest("POST", "http://url.com", *auth)
+	req.RequestType = "form"
+	req.RequestBody = 42
+	assert.NotPanics(func() {
+		assert.NotNil(req.EncodeRequestBody())
+	})
+
+	_, err := structToVals(42, formTagKeys, callLogger{})
+	assert.NotNil(err)
+	_, err = structToVals((*TestOmitEmpty)(nil), formTagKeys, callLogger{})
+	assert.NotNil(err)
+
+	v, err := structToVals(TestOmitEmpty{Kept: 1}, formTagKeys, callLogger{})
+	assert.Nil(err, "Struct values should be accepted as well as pointers")
+	assert.Equal("1", v.Get("kept"))
+}
+
+type TestBaseParams struct {
+	Token string `form:"token"`
+	Page  int    `form:"page"`
+}
+
+type testHiddenParams struct {
+	Trace string `form:"trace"`
+}
+
+type TestEmbedded struct {
+	TestBaseParams
+	*testHiddenParams
+	Page  int    `form:"page"`
+	Query string `form:"q"`
+}
+
+func TestStructToValsEmbedded(t *testing.T) {
+	assert := assert.New(t)
+	s := TestEmbedded{
+		TestBaseParams:   TestBaseParams{Token: "abc", Page: 1},
+		testHiddenParams: &testHiddenParams{Trace: "on"},
+		Page:             2,
+		Query:            "x",
+	}
+	v, err := structToVals(&s, formTagKeys, callLogger{})
+	assert.Nil(err)
+	assert.Equal("abc", v.Get("token"), "Embedded fields should be promoted")
+	assert.Equal("on", v.Get("trace"), "Fields of unexported embedded structs should be promoted")
+	assert.Equal("2", v.Get("page"), "Outer fields should take precedence")
+	assert.Equal("x", v.Get("q"))
+}
+
+type testColor int
+
+func (c testColor) String() string {
+	return [...]string{"red", "green"}[c]
+}
+
+type testID [2]byte
+
+func (id *testID) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%x", id[:])), nil
+}
+
+func TestStructToValsTextMarshaler(t *testing.T) {
+	assert := assert.New(t)
+	v, err := structToVals(&struct {
+		Color testColor `form:"color"`
+		ID    testID    `form:"id"`
+	}{Color: 1, ID: testID{0xab, 0xcd}}, formTagKeys, callLogger{})
+	assert.Nil(err)
+	assert.Equal("green", v.Get("color"))
+	assert.Equal("abcd", v.Get("id"))
+}
+
+type testBadText struct{}
+
+func (testBadText) MarshalText() ([]byte, error) {
+	return nil, errors.New("unencodable")
+}
+
+// A failing MarshalText must fail the encoding rather than drop the field
+func TestStructToValsMarshalTextError(t *testing.T) {
+	assert := assert.New(t)
+	_, err := structToVals(&struct {
+		Bad testBadText `form:"bad"`
+	}{}, formTagKeys, callLogger{})
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "unencodable")
+	}
+
+	req := NewRequest("POST", "http://url.com", *auth)
+	req.RequestType = "form"
+	req.RequestBody = &struct {
+		TestBaseParams
+		Bad testBadText `form:"bad"`
+	}{}
+	assert.NotNil(req.EncodeRequestBody())
+}
+
+func TestStructToValsTime(t *testing.T) {
+	assert := assert.New(t)
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("EST", -5*60*60))
+	v, err := structToVals(struct {
+		Local time.Time  `form:"local"`
+		UTC   time.Time  `form:"utc,utc"`
+		Ptr   *time.Time `form:"ptr,utc"`
+	}{ts, ts, &ts}, formTagKeys, callLogger{})
+	assert.Nil(err)
+	assert.Equal("2020-01-02T03:04:05-05:00", v.Get("local"))
+	assert.Equal("2020-01-02T08:04:05Z", v.Get("utc"))
+	assert.Equal("2020-01-02T08:04:05Z", v.Get("ptr"))
+}
@@ -0,0 +1,148 @@
+package restclient
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// rateLimitPrefixes are the header name prefixes checked, in order,
+// for rate limit information
+var rateLimitPrefixes = []string{"X-RateLimit-", "RateLimit-", "X-Rate-Limit-"}
+
+// SetHeader sets a header to be sent with the request, replacing
+// any existing values
+func (r *Request) SetHeader(key string, value string) *Request {
+	if r.Headers == nil {
+		r.Headers = http.Header{}
+	}
+	r.Headers.Set(key, value)
+	return r
+}
+
+// AcceptCharset sets the Accept-Charset header to the given charset
+// or list (e.g. "utf-8, iso-8859-1;q=0.5").  Set DecodeCharset to
+// read responses in charsets other than UTF-8.
+func (r *Request) AcceptCharset(charset string) *Request {
+	return r.SetHeader("Accept-Charset", charset)
+}
+
+// AcceptLanguage sets the Accept-Language header to the given
+// language tag or list (e.g. "en-US, en;q=0.8")
+func (r *Request) AcceptLanguage(tag string) *Request {
+	return r.SetHeader("Accept-Language", tag)
+}
+
+// IfModifiedSince sets the If-Modified-Since header, formatting
+// the time as required by RFC 7231 (always in GMT)
+func (r *Request) IfModifiedSince(t time.Time) *Request {
+	return r.SetHeader("If-Modified-Since", t.UTC().Format(http.TimeFormat))
+}
+
+// IfUnmodifiedSince sets the If-Unmodified-Since header, formatting
+// the time as required by RFC 7231 (always in GMT).  If the resource
+// has since been modified, the request fails with a
+// PreconditionFailedError.
+func (r *Request) IfUnmodifiedSince(t time.Time) *Request {
+	return r.SetHeader("If-Unmodified-Since", t.UTC().Format(http.TimeFormat))
+}
+
+// Referer sets the Referer header
+func (r *Request) Referer(url string) *Request {
+	return r.SetHeader("Referer", url)
+}
+
+// RateLimitInfo parses the rate limit headers of the response, as
+// sent by many APIs: X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset, or the RateLimit-* and X-Rate-Limit-* variants.
+// The reset may be given as a Unix time or as a number of seconds
+// from now.  It returns ok=false if there is no response or the
+// remaining count is not present.
+func (r *Request) RateLimitInfo() (limit, remaining int, reset time.Time, ok bool) {
+	if r.Response == nil {
+		return 0, 0, time.Time{}, false
+	}
+	h := r.Response.Header
+	for _, prefix := range rateLimitPrefixes {
+		remaining, err := strconv.Atoi(h.Get(prefix + "Remaining"))
+		if err != nil {
+			continue
+		}
+		limit, _ = strconv.Atoi(h.Get(prefix + "Limit"))
+		if secs, err := strconv.ParseInt(h.Get(prefix+"Reset"), 10, 64); err == nil {
+			reset = resetTime(secs, time.Now())
+		}
+		return limit, remaining, reset, true
+	}
+	return 0, 0, time.Time{}, false
+}
+
+// resetTime interprets a rate limit reset value, which is a Unix
+// time if it is implausibly large for a delay, and otherwise a
+// number of seconds from now
+func resetTime(secs int64, now time.Time) time.Time {
+	if secs > 1000000000 {
+		return time.Unix(secs, 0)
+	}
+	return now.Add(time.Duration(secs) * time.Second)
+}
+
+// Trailer returns the trailers of the response, or nil if there is
+// no response.  Trailers are only available once the response body
+// has been read to the end, which Do does unless the body is handed
+// to the caller (as for a 101 Switching Protocols response).
+func (r *Request) Trailer() http.Header {
+	if r.Response == nil {
+		return nil
+	}
+	return r.Response.Trailer
+}
+
+// WithBasicAuth sets the username and password used to authenticate
+// the request, replacing any Authenticator.  Empty strings clear the
+// authentication, so no Authorization header is sent.
+func (r *Request) WithBasicAuth(username string, password string) *Request {
+	r.Auth = Auth{username, password}
+	r.Authenticator = nil
+	return r
+}
+
+// WithBearer authenticates the request with the given bearer token,
+// replacing any other authentication.  An empty token clears the
+// authentication, so no Authorization header is sent.
+func (r *Request) WithBearer(token string) *Request {
+	r.Auth = Auth{}
+	r.Authenticator = nil
+	if token != "" {
+		r.Authenticator = BearerAuth{token}
+	}
+	return r
+}
+
+// RequestID returns the ID sent in the request ID header, whether
+// supplied by the caller or generated (see GenerateRequestID), or
+// the empty string if none was sent
+func (r *Request) RequestID() string {
+	return r.requestID
+}
+
+// setRequestID records the request ID header of the built request,
+// generating it if requested and not already present
+func (r *Request) setRequestID() {
+	header := r.RequestIDHeader
+	if header == "" {
+		header = "X-Request-ID"
+	}
+	r.requestID = r.Request.Header.Get(header)
+	if r.requestID != "" || !r.GenerateRequestID {
+		return
+	}
+	id, err := newUUID()
+	if err != nil {
+		r.logger().Println("Failed to generate request ID:", err)
+		return
+	}
+	r.requestID = id
+	r.Request.Header.Set(header, id)
+	r.logger().Println("Request ID:", id)
+}
@@ -0,0 +1,170 @@
+package restclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderShortcuts(t *testing.T) {
+	assert := assert.New(t)
+	est := time.FixedZone("EST", -5*60*60)
+	req := NewRequest("GET", "http://url.com", *auth)
+	req.AcceptLanguage("en-US").
+		IfModifiedSince(time.Date(2015, 10, 21, 2, 28, 0, 0, est)).
+		Referer("http://referrer.com/")
+	assert.Equal("en-US", req.Headers.Get("Accept-Language"))
+	assert.Equal("Wed, 21 Oct 2015 07:28:00 GMT", req.Headers.Get("If-Modified-Since"))
+	assert.Equal("http://referrer.com/", req.Headers.Get("Referer"))
+}
+
+func TestContentTypeOverride(t *testing.T) {
+	assert := assert.New(t)
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer ts.Close()
+
+	req := NewRequest("POST", ts.URL, *auth)
+	req.RequestBody = TestStructRequest{"hi"}
+	req.SetHeader("Content-Type", "application/vnd.myapi.v2+json")
+	assert.Nil(req.Do())
+	assert.Equal([]string{"application/vnd.myapi.v2+json"}, got["Content-Type"])
+}
+
+// Applying headers to a reused request must not duplicate them
+func TestApplyHeadersTwice(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequest("POST", "http://url.com", *auth)
+	req.RequestType = "form"
+	req.RequestBody = TestStructRequest{"hi"}
+	req.SetHeader("X-Custom", "1")
+	assert.Nil(req.EncodeRequestBody())
+	assert.Nil(req.createHTTPRequest())
+	req.applyHeaders()
+	req.applyHeaders()
+	assert.Equal([]string{"application/x-www-form-urlencoded"}, req.Request.Header["Content-Type"])
+	assert.Equal([]string{"1"}, req.Request.Header["X-Custom"])
+	assert.Equal(1, len(req.Request.Header["Authorization"]))
+}
+
+func TestRateLimitInfo(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequest("GET", "http://url.com", *auth)
+	_, _, _, ok := req.RateLimitInfo()
+	assert.False(ok)
+
+	req.Response = &http.Response{Header: http.Header{}}
+	_, _, _, ok = req.RateLimitInfo()
+	assert.False(ok, "no rate limit headers are present")
+
+	req.Response.Header.Set("X-RateLimit-Limit", "100")
+	req.Response.Header.Set("X-RateLimit-Remaining", "7")
+	req.Response.Header.Set("X-RateLimit-Reset", "1700000000")
+	limit, remaining, reset, ok := req.RateLimitInfo()
+	assert.True(ok)
+	assert.Equal(100, limit)
+	assert.Equal(7, remaining)
+	assert.Equal(int64(1700000000), reset.Unix())
+
+	req.Response.Header = http.Header{}
+	req.Response.Header.Set("RateLimit-Remaining", "0")
+	req.Response.Header.Set("RateLimit-Reset", "30")
+	_, remaining, reset, ok = req.RateLimitInfo()
+	assert.True(ok)
+	assert.Equal(0, remaining)
+	assert.WithinDuration(time.Now().Add(30*time.Second), reset, 5*time.Second)
+}
+
+func TestTrailer(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte(`{"id":1}`))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	defer ts.Close()
+
+	req := NewRequest("GET", ts.URL, *auth)
+	assert.Nil(req.Trailer())
+	req.ResponseBody = new(TestThing)
+	assert.Nil(req.Do())
+	assert.Equal("0", req.Trailer().Get("Grpc-Status"))
+}
+
+func TestWithAuth(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequestBasic("GET", "http://url.com")
+	httpReq, err := req.WithBasicAuth("user", "pass").Build()
+	assert.Nil(err)
+	user, pass, ok := httpReq.BasicAuth()
+	assert.True(ok)
+	assert.Equal("user", user)
+	assert.Equal("pass", pass)
+
+	httpReq, err = req.WithBearer("tok").Build()
+	assert.Nil(err)
+	assert.Equal("Bearer tok", httpReq.Header.Get("Authorization"))
+
+	httpReq, err = req.WithBearer("").Build()
+	assert.Nil(err)
+	assert.Empty(httpReq.Header.Get("Authorization"))
+
+	httpReq, err = req.WithBasicAuth("", "").Build()
+	assert.Nil(err)
+	_, hasAuth := httpReq.Header["Authorization"]
+	assert.False(hasAuth, "empty credentials should send no Authorization header")
+}
+
+func TestRequestID(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequest("GET", "http://url.com", *auth)
+	httpReq, err := req.Build()
+	assert.Nil(err)
+	assert.Empty(httpReq.Header.Get("X-Request-ID"), "request IDs are opt-in")
+	assert.Empty(req.RequestID())
+
+	req.GenerateRequestID = true
+	httpReq, err = req.Build()
+	assert.Nil(err)
+	assert.Len(req.RequestID(), 36)
+	assert.Equal(req.RequestID(), httpReq.Header.Get("X-Request-ID"))
+
+	// A supplied ID is propagated rather than replaced
+	req.RequestIDHeader = "X-Correlation-ID"
+	req.SetHeader("X-Correlation-ID", "abc")
+	httpReq, err = req.Build()
+	assert.Nil(err)
+	assert.Equal("abc", req.RequestID())
+	assert.Equal("abc", httpReq.Header.Get("X-Correlation-ID"))
+}
+
+func TestIfUnmodifiedSince(t *testing.T) {
+	assert := assert.New(t)
+	modified := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since"))
+		if err == nil && modified.After(since) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			w.Write([]byte(`{"message":"modified"}`))
+		}
+	}))
+	defer ts.Close()
+
+	req := NewRequest("PUT", ts.URL, *auth)
+	req.RequestBody = TestStructRequest{"hi"}
+	req.ErrorBody = new(TestErrorMessage)
+	req.IfUnmodifiedSince(modified.Add(-time.Hour))
+	err := req.Do()
+	pfErr, ok := err.(PreconditionFailedError)
+	if assert.True(ok, "a 412 should produce a PreconditionFailedError") {
+		assert.Equal("modified", pfErr.Parsed().(*TestErrorMessage).Message)
+	}
+
+	req.IfUnmodifiedSince(modified)
+	assert.Nil(req.Do())
+}
@@ -0,0 +1,52 @@
+package restclient
+
+import (
+	"time"
+)
+
+// MetricType identifies the point in a request's execution at which
+// a MetricEvent was emitted
+type MetricType string
+
+// MetricType values
+const (
+	MetricStart    MetricType = "start"    // The request is about to be sent
+	MetricResponse MetricType = "response" // A response was received (once per attempt)
+	MetricRetry    MetricType = "retry"    // The response will be retried
+	MetricComplete MetricType = "complete" // The request succeeded
+	MetricError    MetricType = "error"    // The request failed
+)
+
+// MetricEvent describes the state of a request when it is passed to
+// the Metrics hook
+type MetricEvent struct {
+	Type       MetricType
+	Method     string
+	URL        string
+	StatusCode int           // Status code of the latest response (0 if none has been received)
+	Duration   time.Duration // Time since the request started
+	Attempt    int           // Attempt number, starting from 1
+	BytesRead  int64         // Response body bytes read
+	Err        error         // The error, for a MetricError event
+}
+
+// emitMetric calls the Metrics hook, if set, with the current state
+// of the request
+func (r *Request) emitMetric(t MetricType, err error) {
+	if r.Metrics == nil {
+		return
+	}
+	e := MetricEvent{
+		Type:      t,
+		Method:    r.Method,
+		URL:       r.FinalURL(),
+		Duration:  time.Since(r.start),
+		Attempt:   r.stats.Retries + 1,
+		BytesRead: r.stats.BytesRead,
+		Err:       err,
+	}
+	if r.Response != nil {
+		e.StatusCode = r.Response.StatusCode
+	}
+	r.Metrics(e)
+}
@@ -0,0 +1,88 @@
+package restclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	assert := assert.New(t)
+	ts, _ := statusSequence(503)
+	defer ts.Close()
+
+	var events []MetricEvent
+	req := NewRequest("GET", ts.URL, *auth)
+	req.MaxRetries = 1
+	req.RetryBackoff = time.Millisecond
+	req.Metrics = func(e MetricEvent) {
+		events = append(events, e)
+	}
+	assert.Nil(req.Do())
+
+	var types []MetricType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	assert.Equal([]MetricType{MetricStart, MetricResponse, MetricRetry, MetricResponse, MetricComplete}, types)
+	last := events[len(events)-1]
+	assert.Equal("GET", last.Method)
+	assert.Equal(ts.URL, last.URL)
+	assert.Equal(200, last.StatusCode)
+	assert.Equal(2, last.Attempt)
+	assert.Equal(int64(8), last.BytesRead)
+
+	// Failures are reported too
+	ts2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts2.Close()
+	events = nil
+	req = NewRequest("GET", ts2.URL, *auth)
+	req.Metrics = func(e MetricEvent) {
+		events = append(events, e)
+	}
+	assert.NotNil(req.Do())
+	last = events[len(events)-1]
+	assert.Equal(MetricError, last.Type)
+	assert.Equal(404, last.StatusCode)
+	assert.NotNil(last.Err)
+
+	// A reused request does not report the status of its last response
+	ts2.Close()
+	events = nil
+	assert.NotNil(req.Do())
+	for _, e := range events {
+		assert.Equal(0, e.StatusCode, "no response was received for the %s event", e.Type)
+	}
+
+	// As are failures before the request is sent
+	events = nil
+	req = NewRequest("GET", ts2.URL, *auth)
+	req.BeforeRequest = func(*Request) error {
+		return errors.New("refused")
+	}
+	req.Metrics = func(e MetricEvent) {
+		events = append(events, e)
+	}
+	assert.NotNil(req.Do())
+	if assert.Len(events, 1) {
+		assert.Equal(MetricError, events[0].Type)
+		assert.Contains(events[0].Err.Error(), "refused")
+	}
+
+	events = nil
+	req = NewRequest("POST", ts2.URL, *auth)
+	req.RequestBody = make(chan int)
+	req.Metrics = func(e MetricEvent) {
+		events = append(events, e)
+	}
+	assert.NotNil(req.Do())
+	if assert.Len(events, 1, "an encoding failure should be reported") {
+		assert.Equal(MetricError, events[0].Type)
+	}
+}
@@ -0,0 +1,159 @@
+package restclient
+
+import (
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// FilePart is a file (or other raw part) to upload in a multipart
+// request body.  The Reader is streamed into the request, so it may
+// be arbitrarily large, but it can only be read once.
+type FilePart struct {
+	FieldName   string    // Name of the form field
+	FileName    string    // Name of the file, sent in the Content-Disposition
+	ContentType string    // Content-Type of the part (defaults to "application/octet-stream")
+	Reader      io.Reader // Contents of the file
+
+	Header textproto.MIMEHeader // Headers of the part, sent in place of those built from the FileName and ContentType
+}
+
+// AddPart adds a part with the given headers to the multipart body,
+// setting the RequestType to "multipart".  A Content-Disposition
+// naming the form field is added if the header does not have one.
+func (r *Request) AddPart(name string, header textproto.MIMEHeader, reader io.Reader) *Request {
+	if header == nil {
+		header = make(textproto.MIMEHeader)
+	}
+	r.RequestType = "multipart"
+	r.Files = append(r.Files, FilePart{FieldName: name, Header: header, Reader: reader})
+	return r
+}
+
+// AddFilePart adds a file to the multipart body, setting the
+// RequestType to "multipart"
+func (r *Request) AddFilePart(field string, filename string, contentType string, reader io.Reader) *Request {
+	r.RequestType = "multipart"
+	r.Files = append(r.Files, FilePart{FieldName: field, FileName: filename, ContentType: contentType, Reader: reader})
+	return r
+}
+
+// multipartBody streams a multipart body through a pipe.  Writing
+// starts on the first Read, so no goroutine is left behind if the
+// request is built but never sent.
+type multipartBody struct {
+	once     sync.Once
+	pr       *io.PipeReader
+	pw       *io.PipeWriter
+	boundary string
+	write    func(*multipart.Writer) error
+}
+
+func newMultipartBody(write func(*multipart.Writer) error) *multipartBody {
+	pr, pw := io.Pipe()
+	return &multipartBody{
+		pr:       pr,
+		pw:       pw,
+		boundary: multipart.NewWriter(nil).Boundary(),
+		write:    write,
+	}
+}
+
+func (b *multipartBody) Read(p []byte) (int, error) {
+	b.once.Do(func() {
+		go func() {
+			mw := multipart.NewWriter(b.pw)
+			mw.SetBoundary(b.boundary)
+			err := b.write(mw)
+			if err == nil {
+				err = mw.Close()
+			}
+			// Propagate any error to the reader; nil closes normally
+			b.pw.CloseWithError(err)
+		}()
+	})
+	return b.pr.Read(p)
+}
+
+// Close stops the writer, if it is still running
+func (b *multipartBody) Close() error {
+	return b.pr.Close()
+}
+
+// encodeMultipart prepares a streamed multipart/form-data body from
+// the fields of the RequestBody (encoded as for a form) and the Files
+func (r *Request) encodeMultipart() error {
+	r.logger().Printf("Encoding bodyObject (%+v) and %d files to multipart form\n", r.RequestBody, len(r.Files))
+	var fields map[string][]string
+	if r.RequestBody != nil {
+		v, err := structToVals(r.RequestBody, r.tagKeys(), r.logger())
+		if err != nil {
+			return err
+		}
+		fields = v
+	}
+	files := r.Files
+
+	body := newMultipartBody(func(mw *multipart.Writer) error {
+		for k, vals := range fields {
+			for _, val := range vals {
+				if err := mw.WriteField(k, val); err != nil {
+					return err
+				}
+			}
+		}
+		for _, f := range files {
+			if err := writeFilePart(mw, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	r.RequestReader = body
+	r.multipartBoundary = body.boundary
+	return nil
+}
+
+// writeFilePart streams the file into a new part
+func writeFilePart(mw *multipart.Writer, f FilePart) error {
+	w, err := mw.CreatePart(partHeader(f))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f.Reader)
+	return err
+}
+
+// partHeader returns the headers of the part: its Header, with a
+// Content-Disposition added if missing, or else headers built from
+// its FileName and ContentType
+func partHeader(f FilePart) textproto.MIMEHeader {
+	if f.Header != nil {
+		h := make(textproto.MIMEHeader, len(f.Header)+1)
+		for k, v := range f.Header {
+			h[k] = v
+		}
+		if h.Get("Content-Disposition") == "" {
+			h.Set("Content-Disposition", `form-data; name="`+quoteEscaper.Replace(f.FieldName)+`"`)
+		}
+		return h
+	}
+	contentType := f.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", multipartDisposition(f.FieldName, f.FileName))
+	h.Set("Content-Type", contentType)
+	return h
+}
+
+// multipartDisposition formats a form-data Content-Disposition,
+// escaping quotes and backslashes as mime/multipart does
+func multipartDisposition(field string, filename string) string {
+	return `form-data; name="` + quoteEscaper.Replace(field) + `"; filename="` + quoteEscaper.Replace(filename) + `"`
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
@@ -0,0 +1,102 @@
+package restclient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultipart(t *testing.T) {
+	assert := assert.New(t)
+	var fields map[string][]string
+	var file string
+	var fileType string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fields = r.MultipartForm.Value
+		f, h, err := r.FormFile("upload")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b, _ := io.ReadAll(f)
+		file = h.Filename + ":" + string(b)
+		fileType = h.Header.Get("Content-Type")
+	}))
+	defer ts.Close()
+
+	req := NewRequest("POST", ts.URL, *auth)
+	req.RequestType = "multipart"
+	req.RequestBody = map[string]string{"name": "report"}
+	req.Files = []FilePart{{FieldName: "upload", FileName: "a.txt", ContentType: "text/plain", Reader: strings.NewReader("contents")}}
+	assert.Nil(req.Do())
+	assert.Equal([]string{"report"}, fields["name"])
+	assert.Equal("a.txt:contents", file)
+	assert.Equal("text/plain", fileType)
+	assert.Nil(req.Request.GetBody, "the body should be streamed")
+}
+
+func TestAddPart(t *testing.T) {
+	assert := assert.New(t)
+	var parts []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for {
+			p, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			b, _ := io.ReadAll(p)
+			parts = append(parts, p.FormName()+"|"+p.FileName()+"|"+p.Header.Get("Content-Type")+"|"+p.Header.Get("Content-ID")+"|"+string(b))
+		}
+	}))
+	defer ts.Close()
+
+	meta := textproto.MIMEHeader{}
+	meta.Set("Content-Type", "application/json")
+	meta.Set("Content-ID", "<meta>")
+	req := NewRequest("POST", ts.URL, *auth)
+	req.AddPart("metadata", meta, strings.NewReader(`{"title":"a"}`)).
+		AddFilePart("upload", "a.csv", "text/csv", strings.NewReader("1,2"))
+	assert.Equal("multipart", req.RequestType)
+	assert.Nil(req.Do())
+	assert.Equal([]string{
+		`metadata||application/json|<meta>|{"title":"a"}`,
+		"upload|a.csv|text/csv||1,2",
+	}, parts)
+	assert.Empty(meta.Get("Content-Disposition"), "the caller's header should not be modified")
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("disk error")
+}
+
+func TestMultipartReaderError(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+
+	req := NewRequest("POST", ts.URL, *auth)
+	req.RequestType = "multipart"
+	req.Files = []FilePart{{FieldName: "upload", FileName: "a.txt", Reader: failingReader{}}}
+	err := req.Do()
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "disk error")
+}
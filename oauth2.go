@@ -0,0 +1,132 @@
+package restclient
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token is an OAuth2 access token, as returned by a token endpoint
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+
+	Expiry time.Time `json:"-"` // Time at which the token expires (zero if unknown)
+}
+
+// clientCredentialsRequest is the form body of a client credentials grant
+type clientCredentialsRequest struct {
+	GrantType string `form:"grant_type"`
+	Scope     string `form:"scope,omitempty"`
+}
+
+// ClientCredentials obtains an access token from the given token URL
+// using the OAuth2 client credentials grant (RFC 6749 section 4.4).
+// The client ID and secret are sent using Basic authentication.
+func ClientCredentials(tokenURL string, clientID string, clientSecret string, scopes []string) (*Token, Error) {
+	req := clientCredentialsRequest{
+		GrantType: "client_credentials",
+		Scope:     strings.Join(scopes, " "),
+	}
+	token := new(Token)
+	err := PostForm(tokenURL, Auth{clientID, clientSecret}, &req, token)
+	if err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, BaseError{0, "Token Error", fmt.Errorf("Token response did not include an access token")}
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// TokenSource caches an access token obtained through the client
+// credentials grant, refreshing it shortly before it expires.
+// Concurrent callers share a single in-flight refresh.
+type TokenSource struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	RefreshBefore time.Duration // How long before expiry to refresh the token
+
+	mu      sync.Mutex
+	token   *Token
+	pending *tokenCall
+}
+
+// tokenCall is an in-flight token refresh
+type tokenCall struct {
+	done  chan struct{}
+	token *Token
+	err   Error
+}
+
+// NewTokenSource creates a new TokenSource for the given token
+// endpoint and client credentials
+func NewTokenSource(tokenURL string, clientID string, clientSecret string, scopes []string) *TokenSource {
+	return &TokenSource{
+		TokenURL:      tokenURL,
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		Scopes:        scopes,
+		RefreshBefore: 30 * time.Second,
+	}
+}
+
+// Token returns the cached token, refreshing it if it is missing
+// or about to expire
+func (s *TokenSource) Token() (*Token, Error) {
+	s.mu.Lock()
+	if s.token != nil && (s.token.Expiry.IsZero() || time.Now().Add(s.RefreshBefore).Before(s.token.Expiry)) {
+		token := s.token
+		s.mu.Unlock()
+		return token, nil
+	}
+
+	// Join a refresh already in flight
+	if call := s.pending; call != nil {
+		s.mu.Unlock()
+		<-call.done
+		return call.token, call.err
+	}
+
+	call := &tokenCall{done: make(chan struct{})}
+	s.pending = call
+	s.mu.Unlock()
+
+	call.token, call.err = ClientCredentials(s.TokenURL, s.ClientID, s.ClientSecret, s.Scopes)
+
+	s.mu.Lock()
+	if call.err == nil {
+		s.token = call.token
+	}
+	s.pending = nil
+	s.mu.Unlock()
+	close(call.done)
+
+	return call.token, call.err
+}
+
+// BeforeRequest adds the Authorization: Bearer header to the
+// request.  It is intended for use as a BeforeRequest hook.
+func (s *TokenSource) BeforeRequest(r *Request) error {
+	return s.Apply(r.Request)
+}
+
+// Apply adds the Authorization: Bearer header to the request,
+// so that a TokenSource may be used as an Authenticator
+func (s *TokenSource) Apply(req *http.Request) error {
+	token, err := s.Token()
+	if err != nil {
+		return err
+	}
+	return BearerAuth{token.AccessToken}.Apply(req)
+}
@@ -0,0 +1,72 @@
+package restclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientCredentials(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != "id" || pass != "secret" || r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"tok","token_type":"bearer","expires_in":3600,"scope":"` + r.FormValue("scope") + `"}`))
+	}))
+	defer ts.Close()
+
+	token, err := ClientCredentials(ts.URL, "id", "secret", []string{"read", "write"})
+	assert.Nil(err)
+	assert.Equal("tok", token.AccessToken)
+	assert.Equal("read write", token.Scope)
+	assert.WithinDuration(time.Now().Add(time.Hour), token.Expiry, time.Minute)
+
+	_, err = ClientCredentials(ts.URL, "id", "wrong", nil)
+	assert.NotNil(err)
+}
+
+func TestTokenSource(t *testing.T) {
+	assert := assert.New(t)
+	var issued int32
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&issued, 1)
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"tok","expires_in":3600}`))
+	}))
+	defer tokens.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+
+	source := NewTokenSource(tokens.URL, "id", "secret", nil)
+	var wg sync.WaitGroup
+	errs := make([]Error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := NewRequestBasic("GET", api.URL)
+			req.BeforeRequest = source.BeforeRequest
+			errs[i] = req.Do()
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.Nil(err)
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&issued), "Concurrent requests should share one refresh")
+}
@@ -0,0 +1,51 @@
+package restclient
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// OneOf decodes a polymorphic JSON object, whose concrete type is
+// named by a discriminator member (such as "type"), into a value
+// created by the matching factory in Variants.  Set a *OneOf as the
+// ResponseBody (or use one as a field of it) and read the decoded
+// Value after the request.
+type OneOf struct {
+	Field    string                        // Name of the discriminator member (defaults to "type")
+	Variants map[string]func() interface{} // Factories, by discriminator value, returning pointers to decode into
+	Value    interface{}                   // The decoded value, as returned by its factory
+}
+
+// UnmarshalJSON decodes the discriminator, then the whole object
+// into a new value of the matching variant
+func (o *OneOf) UnmarshalJSON(data []byte) error {
+	field := o.Field
+	if field == "" {
+		field = "type"
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	raw, ok := members[field]
+	if !ok {
+		return fmt.Errorf("Missing discriminator %q", field)
+	}
+	var discriminator string
+	if err := json.Unmarshal(raw, &discriminator); err != nil {
+		// Allow non-string discriminators (e.g. numbers) by their text
+		discriminator = string(raw)
+	}
+
+	factory, ok := o.Variants[discriminator]
+	if !ok {
+		return fmt.Errorf("Unknown %s %q", field, discriminator)
+	}
+	v := factory()
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	o.Value = v
+	return nil
+}
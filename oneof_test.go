@@ -0,0 +1,54 @@
+package restclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCircle struct {
+	Type   string  `json:"type"`
+	Radius float64 `json:"radius"`
+}
+
+type testSquare struct {
+	Kind int `json:"kind"`
+	Side int `json:"side"`
+}
+
+func TestOneOf(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/circle":
+			w.Write([]byte(`{"type":"circle","radius":1.5}`))
+		case "/square":
+			w.Write([]byte(`{"kind":4,"side":2}`))
+		default:
+			w.Write([]byte(`{"type":"hexagon"}`))
+		}
+	}))
+	defer ts.Close()
+
+	shapes := map[string]func() interface{}{
+		"circle": func() interface{} { return new(testCircle) },
+		"4":      func() interface{} { return new(testSquare) },
+	}
+
+	ret := &OneOf{Variants: shapes}
+	assert.Nil(Get(ts.URL+"/circle", *auth, ret))
+	assert.Equal(&testCircle{"circle", 1.5}, ret.Value)
+
+	// Non-string discriminators are matched by their text
+	ret = &OneOf{Field: "kind", Variants: shapes}
+	assert.Nil(Get(ts.URL+"/square", *auth, ret))
+	assert.Equal(&testSquare{4, 2}, ret.Value)
+
+	ret = &OneOf{Variants: shapes}
+	err := Get(ts.URL+"/hexagon", *auth, ret)
+	_, ok := err.(DecodeError)
+	assert.True(ok)
+	assert.Nil(ret.Value)
+}
@@ -0,0 +1,33 @@
+package restclient
+
+// Option adjusts a Request made by one of the shorthand helpers
+// (Get, Post, etc.) before it is sent
+type Option func(*Request)
+
+// WithAcceptStatus treats the status codes reported by accept as
+// success, in addition to 2xx (e.g. to accept a 304 Not Modified)
+func WithAcceptStatus(accept func(int) bool) Option {
+	return func(r *Request) {
+		r.AcceptStatus = accept
+	}
+}
+
+// WithStatus treats the given status codes as success, in addition
+// to 2xx
+func WithStatus(codes ...int) Option {
+	return WithAcceptStatus(func(code int) bool {
+		for _, c := range codes {
+			if c == code {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// apply applies the options to the Request
+func (r *Request) apply(opts []Option) {
+	for _, o := range opts {
+		o(r)
+	}
+}
@@ -0,0 +1,29 @@
+package restclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithStatus(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/elsewhere")
+		w.WriteHeader(http.StatusSeeOther)
+	}))
+	defer ts.Close()
+
+	// Redirects are not followed when the response is accepted
+	err := Post(ts.URL, *auth, TestStructRequest{"hi"}, nil, WithStatus(http.StatusSeeOther))
+	assert.Nil(err)
+
+	err = Get(ts.URL, *auth, nil, WithAcceptStatus(func(code int) bool { return code == http.StatusNotModified }))
+	assert.NotNil(err)
+
+	c := NewClient(ts.URL+"/", *auth)
+	_, err = c.GetString("things", WithStatus(http.StatusNotFound))
+	assert.NotNil(err)
+}
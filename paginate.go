@@ -0,0 +1,189 @@
+package restclient
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Paginate repeatedly executes the Request, calling page with the
+// Request after each page has been decoded into its ResponseBody.
+// next is then called to extract the cursor of the following page;
+// iteration stops when it returns false or when page returns an
+// error.
+//
+// If cursorParam is non-empty, the cursor is set as that query
+// parameter of the next request (which is then incompatible with a
+// RawQuery, as that replaces the QueryParameters).  Otherwise, the
+// cursor is treated as the URL of the next page, resolved against the
+// current URL, whose own query replaces the QueryParameters,
+// QueryStruct, OrderedQuery and RawQuery of the Request.
+//
+// Every page is requested with a child of the Request's own Context
+// which is also bounded by ctx, so that a single deadline or
+// cancellation bounds the whole iteration while the Context's values
+// are kept.  No further pages are requested once ctx is done, and the
+// error wraps ctx.Err().  The Request's Context is restored on return.
+func Paginate(ctx context.Context, r *Request, cursorParam string, next func(*Request) (string, bool), page func(*Request) error) Error {
+	r.logger().Println("Paginate: started")
+	orig := r.Context
+	pctx, cancel := r.contextWith(ctx)
+	defer func() {
+		cancel()
+		r.Context = orig
+	}()
+	r.Context = pctx
+	for {
+		if cerr := ctx.Err(); cerr != nil {
+			r.logger().Println("Pagination stopped:", cerr)
+			return transportError(cerr)
+		}
+		err := r.Do()
+		if err != nil {
+			return err
+		}
+		if perr := page(r); perr != nil {
+			return BaseError{0, "Pagination Error", perr}
+		}
+
+		cursor, ok := next(r)
+		if !ok {
+			break
+		}
+		r.logger().Println("Fetching next page:", cursor)
+		if cursorParam != "" {
+			params := make(map[string]string, len(r.QueryParameters)+1)
+			for k, v := range r.QueryParameters {
+				params[k] = v
+			}
+			params[cursorParam] = cursor
+			r.QueryParameters = params
+		} else {
+			u, perr := url.Parse(cursor)
+			if perr != nil {
+				return BaseError{0, "Pagination Error", fmt.Errorf("Invalid next page URL %q: %v", cursor, perr)}
+			}
+			r.Url = r.Request.URL.ResolveReference(u).String()
+			r.QueryParameters = nil
+			r.QueryStruct = nil
+			r.OrderedQuery = nil
+			r.RawQuery = ""
+		}
+
+		// Reset the per-page state; the body is re-encoded by Do
+		if r.RequestBody != nil {
+			r.RequestReader = nil
+		}
+		resetResponseBody(r.ResponseBody)
+	}
+
+	r.logger().Println("Paginate: completed")
+	return nil
+}
+
+// resetResponseBody zeroes the value pointed to by the response
+// body, so that fields absent from the next page are not retained
+func resetResponseBody(body interface{}) {
+	v := reflect.ValueOf(body)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+	}
+}
+
+// NextPageURL returns the URL of the next page given by the rel="next"
+// link of the response's Link header (RFC 8288), as used by GitHub-style
+// APIs.  It may be passed to Paginate as next, with no cursorParam.
+func (r *Request) NextPageURL() (string, bool) {
+	return r.Link("next")
+}
+
+// Link returns the URL of the response's Link header with the given
+// relation type (such as "next", "prev" or "last"), resolved against
+// the URL of the response
+func (r *Request) Link(rel string) (string, bool) {
+	if r.Response == nil {
+		return "", false
+	}
+	for _, l := range parseLinks(r.Response.Header.Values("Link")) {
+		for _, t := range strings.Fields(l.rel) {
+			if !strings.EqualFold(t, rel) {
+				continue
+			}
+			u, err := url.Parse(l.target)
+			if err != nil {
+				return "", false
+			}
+			if r.Response.Request != nil {
+				u = r.Response.Request.URL.ResolveReference(u)
+			}
+			return u.String(), true
+		}
+	}
+	return "", false
+}
+
+// link is a link parsed from a Link header
+type link struct {
+	target string
+	rel    string
+}
+
+// parseLinks parses the values of Link headers, each of which may
+// hold several comma-separated links, such as
+// `<https://api.example.com/items?page=2>; rel="next", <...>; rel=last`
+func parseLinks(values []string) []link {
+	var links []link
+	for _, v := range values {
+		for {
+			start := strings.Index(v, "<")
+			if start == -1 {
+				break
+			}
+			end := strings.Index(v[start:], ">")
+			if end == -1 {
+				break
+			}
+			l := link{target: v[start+1 : start+end]}
+			v = v[start+end+1:]
+
+			// Parameters run to the next comma outside a quoted string
+			params, rest := splitLinkParams(v)
+			for _, param := range params {
+				index := strings.Index(param, "=")
+				if index == -1 || !strings.EqualFold(strings.TrimSpace(param[:index]), "rel") {
+					continue
+				}
+				l.rel = strings.Trim(strings.TrimSpace(param[index+1:]), `"`)
+			}
+			links = append(links, l)
+			v = rest
+		}
+	}
+	return links
+}
+
+// splitLinkParams splits the semicolon-separated parameters of a link
+// from the links following it
+func splitLinkParams(s string) ([]string, string) {
+	var params []string
+	var quoted bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				params = append(params, s[start:i])
+				start = i + 1
+			}
+		case ',':
+			if !quoted {
+				return append(params, s[start:i]), s[i+1:]
+			}
+		}
+	}
+	return append(params, s[start:]), ""
+}
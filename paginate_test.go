@@ -0,0 +1,184 @@
+package restclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type TestPage struct {
+	Items []int  `json:"items"`
+	Next  string `json:"next,omitempty"`
+}
+
+func TestPaginate(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+		if n < 2 {
+			fmt.Fprintf(w, `{"items":[%d],"next":"%d"}`, n, n+1)
+			return
+		}
+		fmt.Fprintf(w, `{"items":[%d]}`, n)
+	}))
+	defer ts.Close()
+
+	type ctxKey struct{}
+	var items []int
+	page := TestPage{}
+	req := NewRequest("GET", ts.URL, *auth)
+	req.ResponseBody = &page
+	req.Context = context.WithValue(context.Background(), ctxKey{}, "value")
+	orig := req.Context
+	err := Paginate(context.Background(), &req, "cursor", func(r *Request) (string, bool) {
+		return page.Next, page.Next != ""
+	}, func(r *Request) error {
+		assert.Equal("value", r.Context.Value(ctxKey{}), "the Request's context values should be kept")
+		items = append(items, page.Items...)
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal([]int{0, 1, 2}, items)
+	assert.Equal(orig, req.Context, "the Request's Context should be restored")
+}
+
+func TestPaginateURL(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page/2" {
+			fmt.Fprint(w, `{"items":[2]}`)
+			return
+		}
+		fmt.Fprint(w, `{"items":[1],"next":"/page/2"}`)
+	}))
+	defer ts.Close()
+
+	pages := 0
+	page := TestPage{}
+	req := NewRequest("GET", ts.URL+"/page/1", *auth)
+	req.ResponseBody = &page
+	err := Paginate(context.Background(), &req, "", func(r *Request) (string, bool) {
+		return page.Next, page.Next != ""
+	}, func(r *Request) error {
+		pages++
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(2, pages)
+	assert.Equal(ts.URL+"/page/2", req.FinalURL())
+}
+
+// The query of a next page URL must replace that of the first request
+func TestPaginateURLQuery(t *testing.T) {
+	assert := assert.New(t)
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `</items?per_page=2&page=2>; rel="next"`)
+		}
+		fmt.Fprint(w, `{"items":[1]}`)
+	}))
+	defer ts.Close()
+
+	req := NewRequest("GET", ts.URL+"/items", *auth)
+	req.RawQuery = "per_page=2"
+	req.ResponseBody = new(TestPage)
+	pages := 0
+	err := Paginate(context.Background(), &req, "", (*Request).NextPageURL, func(r *Request) error {
+		if pages++; pages > 2 {
+			return fmt.Errorf("too many pages")
+		}
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal([]string{"per_page=2", "per_page=2&page=2"}, queries)
+
+	queries = nil
+	pages = 0
+	req = NewRequest("GET", ts.URL+"/items", *auth)
+	req.AddQuery("per_page", "2")
+	req.ResponseBody = new(TestPage)
+	err = Paginate(context.Background(), &req, "", (*Request).NextPageURL, func(r *Request) error {
+		if pages++; pages > 2 {
+			return fmt.Errorf("too many pages")
+		}
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal([]string{"per_page=2", "per_page=2&page=2"}, queries, "the OrderedQuery should not be appended again")
+}
+
+func TestNextPageURL(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "", "1":
+			w.Header().Add("Link", `<https://example.com/ignored>; rel="prev"`)
+			w.Header().Add("Link", `</items?page=2&sort=a,b>; title="x; y, z"; rel="next last", </items?page=1>; rel=first`)
+			fmt.Fprint(w, `{"items":[1]}`)
+		default:
+			w.Header().Set("Link", `</items?page=1>; rel=first`)
+			fmt.Fprint(w, `{"items":[2]}`)
+		}
+	}))
+	defer ts.Close()
+
+	req := NewRequest("GET", ts.URL+"/items", *auth)
+	_, ok := req.NextPageURL()
+	assert.False(ok)
+	assert.Nil(req.Do())
+	next, ok := req.NextPageURL()
+	assert.True(ok)
+	assert.Equal(ts.URL+"/items?page=2&sort=a,b", next)
+	last, _ := req.Link("last")
+	assert.Equal(next, last)
+	first, _ := req.Link("first")
+	assert.Equal(ts.URL+"/items?page=1", first)
+	prev, _ := req.Link("prev")
+	assert.Equal("https://example.com/ignored", prev)
+
+	var items []int
+	page := TestPage{}
+	req = NewRequest("GET", ts.URL+"/items", *auth)
+	req.ResponseBody = &page
+	err := Paginate(context.Background(), &req, "", (*Request).NextPageURL, func(r *Request) error {
+		items = append(items, page.Items...)
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal([]int{1, 2}, items)
+}
+
+func TestPaginateCanceled(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprintf(w, `{"items":[%d],"next":"more"}`, calls)
+	}))
+	defer ts.Close()
+
+	page := TestPage{}
+	req := NewRequest("GET", ts.URL, *auth)
+	req.ResponseBody = &page
+	err := Paginate(ctx, &req, "cursor", func(r *Request) (string, bool) {
+		return page.Next, true
+	}, func(r *Request) error {
+		if calls == 2 {
+			cancel()
+		}
+		return nil
+	})
+	assert.NotNil(err)
+	assert.True(errors.Is(err, context.Canceled))
+	assert.Equal(2, calls, "no pages should be requested after cancellation")
+}
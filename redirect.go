@@ -0,0 +1,42 @@
+package restclient
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// maxRedirects is the number of redirects followed before giving up,
+// as for the http.Client's default policy
+const maxRedirects = 10
+
+// checkRedirect is the http.Client's redirect policy.  It records each
+// redirect followed and stops at redirect responses accepted by
+// AcceptStatus, returning them rather than following them.
+func (r *Request) checkRedirect(req *http.Request, via []*http.Request) error {
+	if r.AcceptStatus != nil && r.AcceptStatus(req.Response.StatusCode) {
+		return http.ErrUseLastResponse
+	}
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", len(via))
+	}
+	// Restart the chain for each attempt
+	r.redirects = append(r.redirects[:len(via)-1], req.URL.String())
+	r.logger().Println("Following redirect to", req.URL.String())
+	return nil
+}
+
+// ResponseURL returns the URL of the request which produced the
+// Response, after following any redirects, or the empty string if
+// there is no Response.  Compare FinalURL, the URL as requested.
+func (r *Request) ResponseURL() string {
+	if r.Response == nil || r.Response.Request == nil {
+		return ""
+	}
+	return r.Response.Request.URL.String()
+}
+
+// Redirects returns the URLs to which the request was redirected, in
+// the order they were followed
+func (r *Request) Redirects() []string {
+	return r.redirects
+}
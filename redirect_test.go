@@ -0,0 +1,50 @@
+package restclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectURLs(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/start":
+			http.Redirect(w, r, "/middle", http.StatusFound)
+		case "/middle":
+			http.Redirect(w, r, "/end?x=1", http.StatusMovedPermanently)
+		default:
+			w.Write([]byte(`{"id":1}`))
+		}
+	}))
+	defer ts.Close()
+
+	r := NewRequest("GET", ts.URL+"/start", *auth)
+	ret := new(TestThing)
+	r.ResponseBody = ret
+	assert.Nil(r.Do())
+	assert.Equal(1, ret.ID)
+	assert.Equal(ts.URL+"/start", r.FinalURL())
+	assert.Equal(ts.URL+"/end?x=1", r.ResponseURL())
+	if assert.Len(r.Redirects(), 2) {
+		assert.Equal(ts.URL+"/middle", r.Redirects()[0])
+		assert.Equal(ts.URL+"/end?x=1", r.Redirects()[1])
+	}
+
+	// Without redirects, the response URL is that requested
+	r = NewRequest("GET", ts.URL+"/end", *auth)
+	assert.Nil(r.Do())
+	assert.Equal(r.FinalURL(), r.ResponseURL())
+	assert.Empty(r.Redirects())
+
+	// Accepted redirects are not followed
+	r = NewRequest("GET", ts.URL+"/start", *auth)
+	r.AcceptStatus = func(code int) bool { return code == http.StatusFound }
+	assert.Nil(r.Do())
+	assert.Equal(http.StatusFound, r.Response.StatusCode)
+	assert.Equal(ts.URL+"/start", r.ResponseURL())
+	assert.Empty(r.Redirects())
+}
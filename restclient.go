@@ -5,7 +5,7 @@
 
 	- Provides transparent JSON marshaling and unmarshaling (assuming appropriately-tagged structs)
 
-	- Support for Basic authentication
+	- Support for Basic and Digest authentication
 
 	- Support for request timeouts (default: 2s)
 
@@ -14,27 +14,51 @@
 	- Returns http.Status and http.StatusType with error
 
 	Both request and response bodies are expected to be pointers to
-	Golang structs with JSON tags.
+	Golang structs with JSON tags.  JSON response bodies may also be
+	pointers to maps or slices (e.g. *map[string]interface{} or
+	*[]Thing) for loosely-typed APIs.
 
 */
 package restclient
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"mime"
 	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"time"
 )
 
-// Logger
+// Logger is the package-level logger, used by Requests which
+// do not have their own Logger
 var Logger *log.Logger
 
+// DefaultTransport, if set, is used by Requests which do not have
+// their own Transport, in place of a new transport per request.  It
+// allows tests to install a mock transport for the package-level
+// helpers.
+var DefaultTransport http.RoundTripper
+
+// defaultKeepAlive is the keep-alive period for connections dialed
+// by the default transport when KeepAlive is not set
+const defaultKeepAlive = 30 * time.Second
+
+// defaultMaxErrorBodyBytes is the limit on the size of error response
+// bodies when MaxErrorBodyBytes is not set
+const defaultMaxErrorBodyBytes = 64 << 10
+
 func init() {
 	// Null logger, by default
 	Logger = log.New(ioutil.Discard, "restclient", log.LstdFlags|log.Lshortfile)
@@ -46,6 +70,37 @@ type Auth struct {
 	Password string
 }
 
+// RateLimiter throttles outgoing requests.  Wait should block until
+// a request may proceed or the context is done.  A
+// *golang.org/x/time/rate.Limiter satisfies this interface.
+type RateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// QueryParam is a single query string parameter
+type QueryParam struct {
+	Key   string
+	Value string
+}
+
+// TransportConfig sets the connection pool limits of the default
+// transport.  Zero values leave the net/http defaults in place.  The
+// limits are only useful for a transport shared between requests, as
+// a Client's is.
+type TransportConfig struct {
+	MaxIdleConns        int           // Maximum idle connections across all hosts
+	MaxIdleConnsPerHost int           // Maximum idle connections to each host (net/http defaults to 2)
+	IdleConnTimeout     time.Duration // How long an idle connection is kept open
+}
+
+// Stats contains basic metrics about the execution of a Request
+type Stats struct {
+	BytesRead  int64         // Number of response body bytes read
+	Duration   time.Duration // Wall-clock duration of Execute
+	StatusCode int           // Final status code (0 if no response was received)
+	Retries    int           // Number of retries made
+}
+
 // Request structures a REST request and provides convenience
 // methods for making REST API calls
 type Request struct {
@@ -53,19 +108,122 @@ type Request struct {
 	Url    string // URL to dial (as expected by net.Dial)
 	Auth   Auth   // Structure for username and password authentication
 
+	MethodOverride bool // Tunnel methods other than GET, HEAD and POST as a POST with an X-HTTP-Method-Override header, for restrictive proxies
+
+	BaseURL string // Base against which a relative Url is resolved (see url.URL.ResolveReference)
+
+	FailoverURLs []string // Further base URLs, tried in order when the request fails with a connection error or 5xx response (see Do; the Url should be relative)
+
+	AuthType string // Authentication scheme for Auth (defaults to "basic", options are: "basic","digest")
+
+	APIKeyHeader string // Header in which to send the APIKeyValue (defaults to "X-API-Key")
+	APIKeyValue  string // API key to send, if any
+
+	Authenticator Authenticator // Applies authentication, replacing the use of Auth and the API key fields
+
+	Headers http.Header // Additional headers to send with the request
+
 	QueryParameters map[string]string // Parameters to attach to the QueryString
-	RequestBody     interface{}       // The body of the request
-	RequestType     string            // Request type for request (defaults to "json", options are: "json","form")
+	QueryStruct     interface{}       // Pointer to a struct to encode into the QueryString (honors "query", "form" and "json" tags)
+	OrderedQuery    []QueryParam      // Parameters appended to the QueryString in the given order, after any others (for APIs which sign the literal query string)
+	RawQuery        string            // Query string sent verbatim, replacing any in the Url and taking precedence over the other query fields
+	RequestBody     interface{}       // The body of the request ([]byte and string bodies are sent verbatim)
+	RequestType     string            // Request type for request (defaults to "json", options are: "json","form","merge-patch","json-patch","ndjson","multipart")
 	ResponseBody    interface{}       // The body of the response
+	ResponseType    string            // Response type for response (defaults to detecting from Content-Type, options are: "json","form")
+	ErrorBody       interface{}       // Decoded from the body of a 4xx or 5xx response, and attached to the returned RequestError or ServerError
+	Files           []FilePart        // Files and other parts streamed in a "multipart" body, after the fields of the RequestBody (see AddPart; the body cannot be rewound for retries)
+
+	FormTagKey string // Struct tag naming form and multipart fields (defaults to "form", falling back to "json")
+
+	StatusBodies map[string]interface{} // Targets to decode into by status code ("404") or class ("2xx", "4xx"), in preference to ResponseBody and ErrorBody
+
+	RequestReader   io.Reader // Reader interface to the encoded body (may be set directly, with a nil RequestBody, to stream a body)
+	ContentLength   int64     // Length of a streamed RequestReader, if known (avoids chunked transfer encoding)
+	ExpectContinue  bool      // Send Expect: 100-continue, so the server may reject the request before the body is sent
+	CompressRequest bool      // Gzip-compress the encoded body (sets Content-Encoding: gzip; not applied to multipart bodies)
+	RequestRaw      []byte    // Encoded request body, exactly as sent (after any compression; not set for streamed bodies)
+	ResponseRaw     []byte    // Raw (usually JSON-encoded) response body (only its first bytes, if it exceeded the size limit)
+	SkipDecode      bool      // Only populate ResponseRaw; never decode into ResponseBody
+	StrictDecode    bool      // Fail JSON decoding if the response contains fields not in ResponseBody
+	UseNumber       bool      // Decode JSON numbers into interface{} values as json.Number rather than float64
+	RequireBody     bool      // Fail with an EmptyResponseError if a response expected to decode into a target has an empty body
+	DecodeCharset   bool      // Transcode a response body to UTF-8 from the charset of its Content-Type (e.g. ISO-8859-1)
+	SniffGzip       bool      // Decompress a response body starting with the gzip magic number, even without a Content-Encoding header
+
+	JSONDisableHTMLEscape bool   // Do not escape <, > and & in encoded JSON strings (by default they are escaped, as by json.Marshal)
+	JSONIndent            string // Indentation for encoded JSON, for readability (defaults to none)
+
+	AcceptEncodings []string // Content codings to advertise in Accept-Encoding (nil keeps Go's transparent gzip; empty sends none and disables it)
+
+	RetryUncompressed bool // Resend once without CompressRequest if the compressed body is rejected with 415 Unsupported Media Type
+
+	MaxResponseBytes int64                // Maximum response body size to read (defaults to unlimited)
+	ValidateResponse func(*Request) error // Optional check of the decoded response; a non-nil error fails the request
 
-	RequestReader io.Reader // Reader interface to the encoded body
-	ResponseRaw   []byte    // Raw (usually JSON-encoded) response body
+	MaxErrorBodyBytes int64 // Maximum error (4xx and 5xx) response body size to read (defaults to 64 KiB, or MaxResponseBytes if smaller)
 
-	Timeout time.Duration // Maximum time to wait for response
+	ClassifyStatus func(int) error // Replaces the built-in status code classification; returning nil means success
+	AcceptStatus   func(int) bool  // Reports further status codes to treat as success; accepted redirects are not followed
+
+	AttemptTimeout time.Duration // Bounds each attempt, including reading its response body, within the Context's overall deadline (only timeouts awaiting the response are retried; see Do)
+
+	MaxRetries       int                       // Maximum number of times to retry a failed response (defaults to 0: no retries)
+	RetryStatusCodes []int                     // Status codes to retry (defaults to 502, 503 and 504 for idempotent methods)
+	RetryOn          func(*http.Response) bool // Selects responses to retry, replacing RetryStatusCodes
+	RetryBackoff     time.Duration             // Delay before the first retry, doubled on each retry (defaults to 100ms; a Retry-After header takes precedence)
+	MaxRetryDelay    time.Duration             // Longest delay before any retry, including one requested by Retry-After (defaults to 30s; negative removes the limit)
+
+	IdempotencyKey string // Sent as the Idempotency-Key header, so the server may deduplicate retried writes (generated for non-idempotent methods when MaxRetries is set)
+
+	LineHandler    func(json.RawMessage) error // Streams an NDJSON response, one line per call, instead of buffering and decoding it (MaxResponseBytes does not apply)
+	ElementHandler func(interface{}) error     // Streams a JSON array response, one decoded element per call, instead of buffering and decoding it (MaxResponseBytes does not apply)
+	NewElement     func() interface{}          // Returns a pointer into which each array element is decoded for ElementHandler (defaults to a new interface{})
+
+	Timeout   time.Duration     // Maximum time to wait for response
+	KeepAlive time.Duration     // Keep-alive period for connections of the default transport (defaults to 30s; negative disables keep-alives)
+	Transport http.RoundTripper // Transport to use for the request (defaults to DefaultTransport, or else a new transport for this request alone, honoring Timeout, KeepAlive and TransportConfig)
+
+	TransportConfig TransportConfig // Connection pool limits for the default transport
+
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error) // Dials connections for the default transport, e.g. to a Unix socket (defaults to a dialer honoring Timeout and KeepAlive)
+
+	Proxy     string // URL of the forward proxy for the default transport; credentials may be given as its userinfo (user:pass@)
+	ProxyAuth Auth   // Credentials for the Proxy, sent as Basic Proxy-Authorization (overrides any userinfo in the Proxy URL)
+
+	Context       context.Context      // Context for the request (defaults to context.Background())
+	RateLimiter   RateLimiter          // Optional limiter shared between requests to throttle dispatch
+	BeforeRequest func(*Request) error // Optional hook run on the built Request just before it is sent
+
+	Metrics func(MetricEvent) // Optional hook called as the request starts, on each response and retry, and on completion or error
+
+	Cache Cache // Caches GET responses carrying an ETag or Last-Modified, making repeated requests conditional
+
+	TraceTimings bool        // Record connection phase timings, available from Timings()
+	Logger       *log.Logger // Logger for this request (defaults to the package-level Logger)
+
+	GenerateRequestID bool   // Send a generated UUID in the RequestIDHeader, unless the header is already set
+	RequestIDHeader   string // Header carrying the request ID (defaults to "X-Request-ID")
 
 	Client   http.Client    // Raw http.Client object
 	Request  *http.Request  // Raw http.Request object
 	Response *http.Response // Raw http.Response object
+
+	stats    Stats
+	trace    *timingsTrace
+	start    time.Time
+	cached   bool
+	events   *eventStream
+	upgraded bool // The response body is an upgraded connection owned by the caller
+
+	ownTransport bool // The transport was built for this request alone
+
+	multipartBoundary string // Boundary of the streamed multipart body
+	requestID         string // ID sent in the RequestIDHeader, if any
+
+	redirects []string // URLs to which the request was redirected, in order
+
+	callID string // ID prefixed to the log lines of the current call
 }
 
 func NewRequest(method string, url string, auth Auth) Request {
@@ -90,6 +248,122 @@ func NewRequestAuth(method string, url string, username string, password string)
 	return NewRequest(method, url, auth)
 }
 
+// Build constructs the http.Request without sending it: the body is
+// encoded and the URL, query parameters, headers and authentication
+// are applied.  The result is also stored in the Request field.  The
+// BeforeRequest hook is not run, as it is only run by Do.
+func (r *Request) Build() (*http.Request, Error) {
+	r.logger().Println("Build: started")
+
+	// Validate the method and URL
+	err := r.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	// Encode body to Json from the given body object
+	err = r.EncodeRequestBody()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the client object
+	r.createHTTPClient()
+
+	// Create the request object
+	err = r.createHTTPRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply headers and authentication
+	err = r.applyHeaders()
+	if err != nil {
+		return nil, err
+	}
+
+	r.logger().Println("Build: completed")
+	return r.Request, nil
+}
+
+// Clone returns a copy of the Request's configuration which may be
+// modified and executed independently.  Headers, QueryParameters and
+// the StatusBodies map are copied rather than shared.  The per-execution fields (Client,
+// Request, Response, ResponseRaw and stats) are cleared, as is the
+// RequestReader if it is encoded from a RequestBody.  Other values,
+// such as the RequestBody and ResponseBody, are shared.
+func (r *Request) Clone() *Request {
+	c := *r
+	c.Headers = r.Headers.Clone()
+	if r.QueryParameters != nil {
+		c.QueryParameters = make(map[string]string, len(r.QueryParameters))
+		for k, v := range r.QueryParameters {
+			c.QueryParameters[k] = v
+		}
+	}
+	if r.StatusBodies != nil {
+		c.StatusBodies = make(map[string]interface{}, len(r.StatusBodies))
+		for k, v := range r.StatusBodies {
+			c.StatusBodies[k] = v
+		}
+	}
+	if r.AcceptEncodings != nil {
+		c.AcceptEncodings = append([]string{}, r.AcceptEncodings...)
+	}
+	if r.FailoverURLs != nil {
+		c.FailoverURLs = append([]string(nil), r.FailoverURLs...)
+	}
+	if r.Files != nil {
+		c.Files = append([]FilePart(nil), r.Files...)
+	}
+	if r.OrderedQuery != nil {
+		c.OrderedQuery = append([]QueryParam(nil), r.OrderedQuery...)
+	}
+	if r.RetryStatusCodes != nil {
+		c.RetryStatusCodes = append([]int(nil), r.RetryStatusCodes...)
+	}
+	if c.RequestBody != nil {
+		c.RequestReader = nil
+		c.RequestRaw = nil
+	}
+	c.Client = http.Client{}
+	c.Request = nil
+	c.Response = nil
+	c.ResponseRaw = nil
+	c.stats = Stats{}
+	c.trace = nil
+	c.upgraded = false
+	c.cached = false
+	c.multipartBoundary = ""
+	c.requestID = ""
+	c.redirects = nil
+	c.callID = ""
+	return &c
+}
+
+// Reset clears the per-execution state of the Request, so that it
+// may be safely reused: the Client, Request and Response, the
+// RequestRaw, RequestReader and ResponseRaw, and the Stats, Timings
+// and RequestID.  All configuration (the method, URL, authentication,
+// headers, bodies and options) is preserved.  Note that a
+// RequestReader set directly is also cleared, as it cannot be re-read.
+func (r *Request) Reset() {
+	r.Client = http.Client{}
+	r.Request = nil
+	r.Response = nil
+	r.RequestRaw = nil
+	r.RequestReader = nil
+	r.ResponseRaw = nil
+	r.stats = Stats{}
+	r.trace = nil
+	r.upgraded = false
+	r.cached = false
+	r.multipartBoundary = ""
+	r.requestID = ""
+	r.redirects = nil
+	r.callID = ""
+}
+
 /*
 	Do makes a (web) request to the url, populating the 'ret' interface provided,
 	and returning the result code from the request
@@ -100,69 +374,378 @@ func NewRequestAuth(method string, url string, username string, password string)
 	Basic HTTP response code classifications are performed and the appropriate error
 	type are returned.
 
+	The Context's deadline bounds the whole call, including any retries and
+	the backoff between them, while the AttemptTimeout bounds each attempt
+	within it.  An attempt whose response headers do not arrive within the
+	AttemptTimeout is retried (if MaxRetries allows, and the request is
+	idempotent) and fails with a TimeoutError only once the retries are
+	exhausted.  The AttemptTimeout also bounds reading the response body,
+	but a timeout there is not retried: it fails the call at once.  The
+	Context's deadline always takes precedence, ending the call without
+	further retries.  The Timeout only bounds establishing each connection.
+
+	If FailoverURLs are set, a request failing with a connection error is
+	retried against each of them in turn, returning the first success or the
+	last error.  A 5xx response is failed over likewise only for an
+	idempotent method or a request with an IdempotencyKey, since the
+	server may already have acted on it.
+
 	In general, this method should not be called directly.
 */
 func (r *Request) Do() Error {
-	Logger.Println("Do: started")
+	r.callID = ""
+	r.logger().Println("Do: started")
 
-	// Encode body to Json from the given body object
-	err := r.EncodeRequestBody()
+	var err Error
+	if len(r.FailoverURLs) == 0 {
+		err = r.do()
+	} else {
+		err = r.doFailover()
+	}
 	if err != nil {
 		return err
 	}
 
-	// Create the client object
-	r.createHTTPClient()
+	r.logger().Println("Do: completed")
+	return nil
+}
 
-	// Create the request object
-	err = r.createHTTPRequest()
+// doFailover makes the request against the BaseURL and then each of
+// the FailoverURLs in turn, until one does not fail with a
+// connection error or (for a request which may safely be repeated) a
+// 5xx response
+func (r *Request) doFailover() Error {
+	base := r.BaseURL
+	defer func() {
+		r.BaseURL = base
+	}()
+
+	var err Error
+	for _, u := range append([]string{base}, r.FailoverURLs...) {
+		r.BaseURL = u
+		err = r.do()
+		if !r.isFailover(err) {
+			return err
+		}
+		r.logger().Println("Request to", u, "failed; failing over:", err)
+		if r.RequestBody != nil {
+			r.RequestReader = nil
+		}
+	}
+	return err
+}
+
+// isFailover reports whether the error warrants trying the request
+// against another host.  A 5xx response may follow a write having
+// taken effect, so it only fails over a request with an idempotent
+// method or an IdempotencyKey.
+func (r *Request) isFailover(err Error) bool {
+	switch err.(type) {
+	case ConnectionError:
+		return true
+	case ServerError, ProblemError:
+		if err.Code() < 500 {
+			return false
+		}
+		return isIdempotent(r.Method) || r.IdempotencyKey != ""
+	}
+	return false
+}
+
+// do builds and sends the request
+func (r *Request) do() Error {
+	err := r.prepare()
 	if err != nil {
 		return err
 	}
 
+	// Send request
+	r.logger().Println("Sending request to server")
+	err = r.Execute()
+	if isUnsupportedMediaType(err) && r.CompressRequest && r.RetryUncompressed && r.RequestBody != nil {
+		err = r.retryUncompressed()
+	}
+	return err
+}
+
+// isUnsupportedMediaType reports whether the error is a 415
+// Unsupported Media Type response, whether or not it carried a
+// problem document
+func isUnsupportedMediaType(err Error) bool {
+	switch err.(type) {
+	case UnsupportedMediaTypeError:
+		return true
+	case ProblemError:
+		return err.Code() == http.StatusUnsupportedMediaType
+	}
+	return false
+}
+
+// prepare builds the request and runs the before-request hook.  A
+// failure is reported to the Metrics hook, as the request is never
+// executed.
+func (r *Request) prepare() (err Error) {
+	defer func() {
+		if err != nil {
+			r.Response = nil
+			r.stats = Stats{}
+			r.start = time.Now()
+			r.emitMetric(MetricError, err)
+		}
+	}()
+
+	// Build the request
+	_, err = r.Build()
+	if err != nil {
+		return err
+	}
+
+	// Run the before-request hook
+	if r.BeforeRequest != nil {
+		if herr := r.BeforeRequest(r); herr != nil {
+			r.logger().Println("Before-request hook failed:", herr)
+			return BaseError{0, "Hook Error", herr}
+		}
+	}
+	return nil
+}
+
+// retryUncompressed resends a request whose compressed body was
+// rejected, without compression
+func (r *Request) retryUncompressed() Error {
+	r.logger().Println("Compressed body rejected; retrying uncompressed")
+	r.CompressRequest = false
+	defer func() {
+		r.CompressRequest = true
+	}()
+
+	if err := r.prepare(); err != nil {
+		return err
+	}
+	return r.Execute()
+}
+
+// applyHeaders applies the additional headers, Content-Type and
+// authentication information to the http.Request.  Headers are
+// replaced rather than appended, so it is safe to apply them again
+// to a reused request.
+func (r *Request) applyHeaders() Error {
+	// Apply additional headers
+	for k, v := range r.Headers {
+		r.Request.Header[k] = append([]string(nil), v...)
+	}
+
+	// Set the Content-Type for the RequestType; requests without a
+	// body (such as a GET or DELETE) carry no Content-Type
+	if r.RequestReader != nil {
+		r.setContentType()
+	}
+	if r.CompressRequest && r.RequestBody != nil && r.RequestType != "multipart" {
+		r.Request.Header.Set("Content-Encoding", "gzip")
+	}
+	r.setConditionalHeaders()
+	r.setRequestID()
+	if r.Request.Method != r.Method {
+		r.Request.Header.Set("X-HTTP-Method-Override", r.Method)
+	}
+	r.setAcceptEncoding()
+	if r.ExpectContinue && r.Request.Body != nil {
+		r.Request.Header.Set("Expect", "100-continue")
+	}
+	if key := r.idempotencyKey(); key != "" {
+		r.Request.Header.Set("Idempotency-Key", key)
+	}
+
+	// Apply authentication information
+	if r.Authenticator != nil {
+		r.logger().Printf("Applying authenticator: (%T)", r.Authenticator)
+		if err := r.Authenticator.Apply(r.Request); err != nil {
+			r.logger().Println("Failed to apply authenticator:", err)
+			return BaseError{0, "Authentication Error", err}
+		}
+		return nil
+	}
+
+	// Otherwise use the Auth and API key fields; digest authentication
+	// is applied in response to the server's challenge
+	if r.Auth.Username != "" && r.AuthType != "digest" {
+		r.logger().Printf("Adding authentication information: (%+v)", r.Auth)
+		BasicAuth{r.Auth.Username, r.Auth.Password}.Apply(r.Request)
+	}
+	if r.APIKeyValue != "" {
+		key := APIKeyAuth{r.APIKeyHeader, r.APIKeyValue}
+		r.logger().Println("Adding API key header:", key.header())
+		key.Apply(r.Request)
+	}
+	return nil
+}
+
+// setContentType sets the Content-Type header according to the
+// RequestType, unless a Content-Type was supplied in the Headers
+func (r *Request) setContentType() {
+	if r.Headers.Get("Content-Type") != "" {
+		r.logger().Println("Using supplied Content-Type:", r.Headers.Get("Content-Type"))
+		return
+	}
+
+	var contentType string
 	switch r.RequestType {
 	case "":
-		Logger.Println("No RequestType specified; using json")
-		r.Request.Header.Add("Content-Type", "application/json")
+		r.logger().Println("No RequestType specified; using json")
+		contentType = "application/json"
 	case "json":
-		r.Request.Header.Add("Content-Type", "application/json")
+		contentType = "application/json"
 	case "form":
-		r.Request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		contentType = "application/x-www-form-urlencoded"
+	case "merge-patch":
+		contentType = "application/merge-patch+json"
+	case "json-patch":
+		contentType = "application/json-patch+json"
+	case "ndjson":
+		contentType = "application/x-ndjson"
+	case "multipart":
+		contentType = "multipart/form-data; boundary=" + r.multipartBoundary
 	default:
-		Logger.Println("Unhandled request type:", r.RequestType)
+		r.logger().Println("Unhandled request type:", r.RequestType)
+		return
 	}
+	r.Request.Header.Set("Content-Type", contentType)
+}
 
-	// Apply authentication information
-	if r.Auth.Username != "" {
-		Logger.Printf("Adding authentication information: (%+v)", r.Auth)
-		r.Request.SetBasicAuth(r.Auth.Username, r.Auth.Password)
+// Validate checks the Request's Method and Url, returning a
+// descriptive error if they could not be used to make a request
+func (r *Request) Validate() Error {
+	if r.Method == "" {
+		return BaseError{0, "Validation Error", fmt.Errorf("Method must not be empty")}
+	}
+	if r.Method != strings.ToUpper(r.Method) || strings.IndexFunc(r.Method, isNotTokenChar) != -1 {
+		return BaseError{0, "Validation Error", fmt.Errorf("Method %q is not a canonical HTTP method", r.Method)}
+	}
+	if r.Url == "" && r.BaseURL == "" {
+		return BaseError{0, "Validation Error", fmt.Errorf("Url must not be empty")}
 	}
+	u, err := r.resolveURL()
+	if err != nil {
+		return BaseError{0, "Validation Error", err}
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return BaseError{0, "Validation Error", fmt.Errorf("Url %q must be absolute (scheme and host)", u.String())}
+	}
+	if _, err = r.proxyURL(); err != nil {
+		return BaseError{0, "Validation Error", err}
+	}
+	return nil
+}
 
-	// Send request
-	Logger.Println("Sending request to server")
-	err = r.Execute()
+// proxyURL parses the Proxy, applying the ProxyAuth credentials if
+// set.  It returns nil if no Proxy is set.
+func (r *Request) proxyURL() (*url.URL, error) {
+	if r.Proxy == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(r.Proxy)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("Proxy %q could not be parsed: %v", r.Proxy, err)
 	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("Proxy %q must be absolute (scheme and host)", r.Proxy)
+	}
+	if r.ProxyAuth.Username != "" {
+		u.User = url.UserPassword(r.ProxyAuth.Username, r.ProxyAuth.Password)
+	}
+	return u, nil
+}
 
-	Logger.Println("Do: completed")
-	return nil
+// resolveURL parses the Url, resolving it against the BaseURL
+// if one is set
+func (r *Request) resolveURL() (*url.URL, error) {
+	u, err := url.Parse(r.Url)
+	if err != nil {
+		return nil, fmt.Errorf("Url %q could not be parsed: %v", r.Url, err)
+	}
+	if r.BaseURL == "" {
+		return u, nil
+	}
+	base, err := url.Parse(r.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("BaseURL %q could not be parsed: %v", r.BaseURL, err)
+	}
+	return base.ResolveReference(u), nil
+}
+
+// isNotTokenChar reports whether the rune is not permitted in an
+// HTTP token (RFC 7230), such as a method name
+func isNotTokenChar(c rune) bool {
+	if c > 127 || c <= ' ' {
+		return true
+	}
+	return strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c)
 }
 
 // Execute transacts with the remote server, actually executing
 // the Request with the Client.  It sets the Response property on
-// successful communication
+// successful communication, clearing any Response of a previous
+// execution
 func (r *Request) Execute() Error {
-	Logger.Println("Execute: started")
-	var cerr error
-	r.Response, cerr = r.Client.Do(r.Request)
+	r.logger().Println("Execute: started")
+	r.Response = nil
+	r.ResponseRaw = nil
+	r.stats = Stats{}
+	r.start = time.Now()
+	r.cached = false
+	r.redirects = nil
+	r.emitMetric(MetricStart, nil)
+
+	err := r.execute()
+	r.stats.Duration = time.Since(r.start)
+	if err != nil {
+		r.emitMetric(MetricError, err)
+	} else {
+		r.emitMetric(MetricComplete, nil)
+	}
+	return err
+}
+
+// execute sends the request and processes the response for Execute
+func (r *Request) execute() Error {
+	// Wait for the rate limiter, if one is set
+	if r.RateLimiter != nil {
+		r.logger().Println("Waiting on rate limiter")
+		if werr := r.RateLimiter.Wait(r.context()); werr != nil {
+			r.logger().Println("Rate limiter wait failed:", werr)
+			return BaseError{0, "Rate Limit Error", werr}
+		}
+	}
+
+	cerr := r.send()
 	if cerr != nil {
-		Logger.Println("Failed to make request to server:", cerr)
-		return BaseError{0, "Unknown Error", cerr}
+		r.logger().Println("Failed to make request to server:", cerr)
+		return transportError(cerr)
 	}
-	defer r.Response.Body.Close()
 
-	Logger.Println("Server response:", r.Response)
+	// Answer a digest authentication challenge
+	if r.AuthType == "digest" && r.Auth.Username != "" && isDigestChallenge(r.Response) {
+		r.logger().Println("Received digest challenge")
+		cerr = r.digestRetry()
+		if cerr != nil {
+			r.logger().Println("Failed to answer digest challenge:", cerr)
+			return BaseError{0, "Authentication Error", cerr}
+		}
+	}
+	r.upgraded = false
+	defer func() {
+		if !r.upgraded {
+			r.Response.Body.Close()
+		}
+		if r.ownTransport {
+			// Nothing else will use the transport's pooled connections
+			r.Client.CloseIdleConnections()
+		}
+	}()
+	r.stats.StatusCode = r.Response.StatusCode
+
+	r.logger().Println("Server response:", r.Response)
+	r.useCachedResponse()
 
 	// Check for error codes
 	var err Error
@@ -176,18 +759,37 @@ func (r *Request) Execute() Error {
 	if err != nil {
 		return err
 	}
+	r.storeResponse()
+
+	// Validate the response
+	if r.ValidateResponse != nil {
+		if verr := r.ValidateResponse(r); verr != nil {
+			r.logger().Println("Response failed validation:", verr)
+			return BaseError{r.Response.StatusCode, "Response Validation Error", verr}
+		}
+	}
 
-	Logger.Println("MakeRequest: completed")
+	r.logger().Println("MakeRequest: completed")
 	return nil
 }
 
 // EncodeRequestBody performs the selected encoding on the
-// provided request body, populating the RequestReader
+// provided request body, populating the RequestReader.  A
+// []byte or string body is sent verbatim.
 func (r *Request) EncodeRequestBody() Error {
-	Logger.Println("EncodeRequestBody: started")
+	r.logger().Println("EncodeRequestBody: started")
 	// Encode body to Json from the given body object
+	if r.RequestType == "multipart" {
+		// Multipart bodies are streamed rather than encoded up front
+		if err := r.encodeMultipart(); err != nil {
+			r.logger().Println("Failed to encode multipart:", err.Error())
+			return r.encodeError(err)
+		}
+		r.logger().Println("EncodeRequestBody: completed")
+		return nil
+	}
 	if r.RequestBody == nil {
-		Logger.Println("Nothing to encode")
+		r.logger().Println("Nothing to encode")
 		return nil
 	}
 
@@ -197,176 +799,818 @@ func (r *Request) EncodeRequestBody() Error {
 	}
 	var encodedBytes []byte
 	var err error
-	switch r.RequestType {
-	case "form":
-		encodedBytes, err = r.encodeForm()
-		if err != nil {
-			Logger.Println("Failed to encode form:", err.Error())
-			return BaseError{0, "Encoding Error", err}
+	switch body := r.RequestBody.(type) {
+	case []byte:
+		r.logger().Println("Using raw request body")
+		encodedBytes = body
+	case string:
+		r.logger().Println("Using raw request body")
+		encodedBytes = []byte(body)
+	default:
+		switch r.RequestType {
+		case "form":
+			encodedBytes, err = r.encodeForm()
+			if err != nil {
+				r.logger().Println("Failed to encode form:", err.Error())
+				return r.encodeError(err)
+			}
+		case "json", "merge-patch", "json-patch":
+			encodedBytes, err = r.encodeJson()
+			if err != nil {
+				r.logger().Println("Failed to encode json:", err.Error())
+				return r.encodeError(err)
+			}
+		case "ndjson":
+			encodedBytes, err = r.encodeNDJSON()
+			if err != nil {
+				r.logger().Println("Failed to encode ndjson:", err.Error())
+				return r.encodeError(err)
+			}
 		}
-	case "json":
-		encodedBytes, err = r.encodeJson()
+	}
+
+	// Compress the encoded body
+	if r.CompressRequest {
+		encodedBytes, err = gzipBytes(encodedBytes)
 		if err != nil {
-			Logger.Println("Failed to encode form:", err.Error())
-			return BaseError{0, "Encoding Error", err}
+			r.logger().Println("Failed to compress body:", err.Error())
+			return r.encodeError(err)
 		}
 	}
 
+	r.RequestRaw = encodedBytes
 	r.RequestReader = bytes.NewReader(encodedBytes)
-	Logger.Println("EncodeRequestBody: completed")
+	r.logger().Println("EncodeRequestBody: completed")
 	return nil
 }
 
+// encodeError wraps a failure to encode the request body
+func (r *Request) encodeError(err error) EncodeError {
+	return EncodeError{BaseError{0, "Encoding Error", err}, r.Method, r.Url, r.RequestType}
+}
+
+// gzipBytes gzip-compresses the given bytes
+func gzipBytes(in []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(in); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // encodeJson encodes the request body to Json
 func (r *Request) encodeJson() ([]byte, error) {
-	Logger.Printf("Encoding bodyObject (%+v) to json", r.RequestBody)
-	return json.Marshal(r.RequestBody)
+	r.logger().Printf("Encoding bodyObject (%+v) to json", r.RequestBody)
+	if !r.JSONDisableHTMLEscape && r.JSONIndent == "" {
+		return json.Marshal(r.RequestBody)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(!r.JSONDisableHTMLEscape)
+	enc.SetIndent("", r.JSONIndent)
+	if err := enc.Encode(r.RequestBody); err != nil {
+		return nil, err
+	}
+	// Drop the newline added by the Encoder, for parity with json.Marshal
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
 }
 
 // ProcessStatusCode processes and returns classified errors resulting
-// from the Response's StatusCode
+// from the Response's StatusCode, using ClassifyStatus if it is set
 func (r *Request) ProcessStatusCode() Error {
-	Logger.Println("ProcessStatusCode: started")
+	r.logger().Println("ProcessStatusCode: started")
 	resp := r.Response
+
+	// Accept the caller's further success codes
+	if r.AcceptStatus != nil && r.AcceptStatus(resp.StatusCode) {
+		r.logger().Println("ProcessStatusCode: completed")
+		return nil
+	}
+
+	// Use the caller's classification, if supplied
+	if r.ClassifyStatus != nil {
+		err := r.ClassifyStatus(resp.StatusCode)
+		if err == nil {
+			r.logger().Println("ProcessStatusCode: completed")
+			return nil
+		}
+		r.logger().Printf("Status classified as error: (%d) %s", resp.StatusCode, resp.Status)
+		r.decodeErrorBody()
+		if e, ok := err.(Error); ok {
+			return e
+		}
+		return BaseError{resp.StatusCode, resp.Status, err}
+	}
 	if (resp.StatusCode >= 300) || (resp.StatusCode < 200) {
-		Logger.Printf("Non-2XX response: (%d) %s", resp.StatusCode, resp.Status)
+		r.logger().Printf("Non-2XX response: (%d) %s", resp.StatusCode, resp.Status)
 		switch {
+		case resp.StatusCode == http.StatusSwitchingProtocols:
+			// Hand the upgraded connection to the caller rather than closing it
+			conn, _ := resp.Body.(io.ReadWriteCloser)
+			r.upgraded = conn != nil
+			protocol := resp.Header.Get("Upgrade")
+			return SwitchingProtocolsError{BaseError{resp.StatusCode, resp.Status, fmt.Errorf("Request: Server switched protocols to %q; use a client for that protocol (e.g. a WebSocket library) with the returned connection", protocol)}, protocol, conn}
+		case resp.StatusCode >= 100 && resp.StatusCode < 200:
+			return BaseError{resp.StatusCode, resp.Status, fmt.Errorf("Request: Unexpected informational response: %s", resp.Status)}
+		case resp.StatusCode >= 400 && resp.StatusCode < 600 && r.isProblem():
+			// Problem documents take precedence over the specific errors
+			return r.problemError()
+		case resp.StatusCode == http.StatusUnauthorized:
+			return UnauthorizedError{BaseError{resp.StatusCode, resp.Status, fmt.Errorf("Request: Unauthorized: %s", resp.Status)}, errorBody{r.decodeErrorBody()}}
+		case resp.StatusCode == http.StatusForbidden:
+			return ForbiddenError{BaseError{resp.StatusCode, resp.Status, fmt.Errorf("Request: Forbidden: %s", resp.Status)}, errorBody{r.decodeErrorBody()}}
+		case resp.StatusCode == http.StatusPreconditionFailed:
+			return PreconditionFailedError{BaseError{resp.StatusCode, resp.Status, fmt.Errorf("Request: Precondition Failed: %s", resp.Status)}, errorBody{r.decodeErrorBody()}}
+		case resp.StatusCode == http.StatusUnsupportedMediaType:
+			return UnsupportedMediaTypeError{BaseError{resp.StatusCode, resp.Status, fmt.Errorf("Request: Unsupported Media Type: %s", resp.Status)}, errorBody{r.decodeErrorBody()}, r.Request.Header.Get("Content-Type"), r.Request.Header.Get("Content-Encoding")}
 		case resp.StatusCode == 404:
-			return BaseError{resp.StatusCode, resp.Status, fmt.Errorf("Request: Not Found: %s", resp.Status)}
+			return RequestError{BaseError{resp.StatusCode, resp.Status, fmt.Errorf("Request: Not Found: %s", resp.Status)}, errorBody{r.decodeErrorBody()}}
 		case resp.StatusCode >= 400 && resp.StatusCode < 500:
-			return BaseError{resp.StatusCode, resp.Status, fmt.Errorf("Request: Request Error: %s", resp.Status)}
+			return RequestError{BaseError{resp.StatusCode, resp.Status, fmt.Errorf("Request: Request Error: %s", resp.Status)}, errorBody{r.decodeErrorBody()}}
 		case resp.StatusCode >= 500 && resp.StatusCode < 600:
-			return BaseError{resp.StatusCode, resp.Status, fmt.Errorf("Request: Server Error: %s", resp.Status)}
+			return ServerError{BaseError{resp.StatusCode, resp.Status, fmt.Errorf("Request: Server Error: %s", resp.Status)}, errorBody{r.decodeErrorBody()}}
 		default:
 			return BaseError{0, "Unhandled Status", fmt.Errorf("Unhandled StatusCode: %s", resp.Status)}
 		}
 	}
 
-	Logger.Println("ProcessStatusCode: completed")
+	r.logger().Println("ProcessStatusCode: completed")
 	return nil
 }
 
+// DecodeResponse reads the response body into ResponseRaw and,
+// unless SkipDecode is set or there is no ResponseBody, decodes
+// it into the ResponseBody according to the ResponseType
 func (r *Request) DecodeResponse() Error {
-	Logger.Println("DecodeResponse: started")
+	r.logger().Println("DecodeResponse: started")
+
+	// Responses which cannot carry a body are never decoded
+	if r.NoContent() {
+		r.logger().Println("No content in response")
+		r.ResponseRaw = nil
+		r.logger().Println("DecodeResponse: completed")
+		return nil
+	}
+
+	// Stream events to a subscription
+	if r.events != nil {
+		if err := r.decodeEvents(); err != nil {
+			r.logger().Println("Failed to decode event stream:", err)
+			return BaseError{0, "Decode Error", fmt.Errorf("Failed to decode response: %v", err)}
+		}
+		r.logger().Println("DecodeResponse: completed")
+		return nil
+	}
+
+	// Stream NDJSON responses to the handler rather than buffering
+	if r.LineHandler != nil && !r.SkipDecode {
+		if err := r.decodeNDJSON(); err != nil {
+			r.logger().Println("Failed to decode NDJSON response:", err)
+			return BaseError{0, "Decode Error", fmt.Errorf("Failed to decode response: %v", err)}
+		}
+		r.logger().Println("DecodeResponse: completed")
+		return nil
+	}
 
-	// Read the body into []byte
-	responseJson, err := ioutil.ReadAll(r.Response.Body)
+	// Stream JSON array responses element by element
+	if r.ElementHandler != nil && !r.SkipDecode {
+		if err := r.decodeJSONArray(); err != nil {
+			r.logger().Println("Failed to decode JSON array response:", err)
+			return BaseError{0, "Decode Error", fmt.Errorf("Failed to decode response: %v", err)}
+		}
+		r.logger().Println("DecodeResponse: completed")
+		return nil
+	}
+
+	// Read the body into []byte, up to the limit
+	err := r.readBody()
 	if err != nil {
-		Logger.Println("Failed to read from body:", r.Response.Body, err)
-		return BaseError{0, "Decode Error", fmt.Errorf("Failed to read from body:", err)}
+		return err
+	}
+
+	target := r.ResponseBody
+	if body, ok := r.statusBody(r.Response.StatusCode); ok {
+		target = body
+	}
+	if r.SkipDecode || target == nil {
+		r.logger().Println("Skipping decode of response body")
+		r.logger().Println("DecodeResponse: completed")
+		return nil
 	}
 
 	// Unmarshal into response object
-	if len(responseJson) > 0 {
-		Logger.Println("Decoding response")
-		err = json.Unmarshal(responseJson, r.ResponseBody)
-		if err != nil {
-			Logger.Println("Failed to decode response body:", responseJson, err)
-			return BaseError{0, "Decode Error", fmt.Errorf("Failed to decode response: %v", err.Error())}
+	if len(r.ResponseRaw) > 0 {
+		r.logger().Println("Decoding response")
+		if derr := r.decodeBody(r.ResponseRaw, target); derr != nil {
+			r.logger().Println("Failed to decode response body:", r.ResponseRaw, derr)
+			return r.decodeError(derr, target)
 		}
+	} else if r.RequireBody {
+		r.logger().Println("Zero-length response body where one is required")
+		return EmptyResponseError{BaseError{r.Response.StatusCode, r.Response.Status, fmt.Errorf("Request: Empty response body: %s", r.Response.Status)}}
 	} else {
-		Logger.Println("Zero-length response body")
+		r.logger().Println("Zero-length response body")
 	}
 
-	Logger.Println("DecodeResponse: completed")
+	r.logger().Println("DecodeResponse: completed")
+	return nil
+}
+
+// ResponseString returns the raw response body as a string
+func (r *Request) ResponseString() string {
+	return string(r.ResponseRaw)
+}
+
+// NoContent reports whether the response is one which carries no
+// body: a 204 No Content or 205 Reset Content, or the response to a
+// HEAD request.  Such responses are never decoded, so a ResponseBody
+// may be left set for a write which may or may not return content.
+func (r *Request) NoContent() bool {
+	if r.Response == nil {
+		return false
+	}
+	switch r.Response.StatusCode {
+	case http.StatusNoContent, http.StatusResetContent:
+		return true
+	}
+	return r.Response.Request != nil && r.Response.Request.Method == "HEAD"
+}
+
+// decodeError wraps a failure to decode the response body with
+// the details needed to diagnose it
+func (r *Request) decodeError(err error, target interface{}) DecodeError {
+	body := r.ResponseRaw
+	if len(body) > maxDecodeErrorBody {
+		body = body[:maxDecodeErrorBody]
+	}
+	contentType := r.Response.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = r.responseType()
+	}
+	return DecodeError{BaseError{0, "Decode Error", err}, contentType, body, fmt.Sprintf("%T", target)}
+}
+
+// readBody reads the response body into ResponseRaw, up to the
+// MaxResponseBytes limit
+func (r *Request) readBody() Error {
+	return r.readBodyLimit(r.MaxResponseBytes)
+}
+
+// readBodyLimit reads the body as readBody, failing if it exceeds the
+// given limit (if positive).  The ResponseRaw is then left holding the
+// first limit bytes, which may help to diagnose an oversized error
+// response.
+func (r *Request) readBodyLimit(limit int64) Error {
+	r.ResponseRaw = nil
+	body, err := r.decodeResponseContent(r.Response.Body)
+	if err != nil {
+		r.logger().Println("Failed to decode content:", err)
+		return BaseError{0, "Decode Error", fmt.Errorf("Failed to read from body: %v", err)}
+	}
+	if limit > 0 {
+		body = io.LimitReader(body, limit+1)
+	}
+	responseJson, err := ioutil.ReadAll(body)
+	if err != nil {
+		r.logger().Println("Failed to read from body:", r.Response.Body, err)
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			// The AttemptTimeout or Context expired during the read
+			return transportError(err)
+		}
+		return BaseError{0, "Decode Error", fmt.Errorf("Failed to read from body: %v", err)}
+	}
+	if limit > 0 && int64(len(responseJson)) > limit {
+		r.logger().Println("Response body exceeds limit of", limit, "bytes")
+		r.ResponseRaw = responseJson[:limit]
+		r.stats.BytesRead = int64(len(responseJson))
+		return ResponseTooLargeError{BaseError{0, "Response Too Large", fmt.Errorf("Response body exceeds limit of %d bytes", limit)}, limit}
+	}
+	r.ResponseRaw = responseJson
+	r.stats.BytesRead = int64(len(responseJson))
 	return nil
 }
 
+// decodeBody decodes the response body into v according to the
+// ResponseType
+func (r *Request) decodeBody(body []byte, v interface{}) error {
+	switch r.responseType() {
+	case "form":
+		return r.decodeForm(body, v)
+	default:
+		return r.decodeJson(body, v)
+	}
+}
+
+// decodeErrorBody reads the body of an error response into
+// ResponseRaw and decodes it into the ErrorBody, if one is set.  It
+// returns the ErrorBody if it was populated, and nil otherwise.
+func (r *Request) decodeErrorBody() interface{} {
+	if r.Response.Body == nil {
+		return nil
+	}
+	if err := r.readBodyLimit(r.maxErrorBodyBytes()); err != nil {
+		r.logger().Println("Failed to read error response body:", err)
+		return nil
+	}
+	target := r.ErrorBody
+	if body, ok := r.statusBody(r.Response.StatusCode); ok {
+		target = body
+	}
+	if target == nil || len(r.ResponseRaw) == 0 {
+		return nil
+	}
+	if err := r.decodeBody(r.ResponseRaw, target); err != nil {
+		r.logger().Println("Failed to decode error response body:", err)
+		return nil
+	}
+	return target
+}
+
+// isProblem reports whether the response is an RFC 7807 problem
+// document
+func (r *Request) isProblem() bool {
+	mediaType, _, err := mime.ParseMediaType(r.Response.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/problem+json"
+}
+
+// problemError decodes the problem document of an error response
+// into a ProblemError, also decoding the ErrorBody, if any
+func (r *Request) problemError() Error {
+	resp := r.Response
+	body := r.decodeErrorBody()
+	var p problemDocument
+	if err := json.Unmarshal(r.ResponseRaw, &p); err != nil {
+		r.logger().Println("Failed to decode problem document:", err)
+	}
+	msg := p.Title
+	if msg == "" {
+		msg = resp.Status
+	}
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+	return ProblemError{BaseError{resp.StatusCode, resp.Status, fmt.Errorf("Request: Problem: %s", msg)}, errorBody{body}, p}
+}
+
+// maxErrorBodyBytes returns the limit on the size of error response
+// bodies: the MaxErrorBodyBytes, or MaxResponseBytes if smaller
+func (r *Request) maxErrorBodyBytes() int64 {
+	limit := r.MaxErrorBodyBytes
+	if limit <= 0 {
+		limit = defaultMaxErrorBodyBytes
+	}
+	if r.MaxResponseBytes > 0 && r.MaxResponseBytes < limit {
+		limit = r.MaxResponseBytes
+	}
+	return limit
+}
+
+// statusBody returns the StatusBodies target for the status code,
+// preferring an exact match ("404") to a class ("4xx")
+func (r *Request) statusBody(code int) (interface{}, bool) {
+	if r.StatusBodies == nil {
+		return nil, false
+	}
+	if body, ok := r.StatusBodies[strconv.Itoa(code)]; ok {
+		return body, true
+	}
+	body, ok := r.StatusBodies[strconv.Itoa(code/100)+"xx"]
+	return body, ok
+}
+
+// decodeJson decodes the JSON response body into v
+func (r *Request) decodeJson(body []byte, v interface{}) error {
+	if !r.StrictDecode && !r.UseNumber {
+		return json.Unmarshal(body, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if r.StrictDecode {
+		dec.DisallowUnknownFields()
+	}
+	if r.UseNumber {
+		dec.UseNumber()
+	}
+	return dec.Decode(v)
+}
+
+// responseType returns the ResponseType, detecting it from the
+// response's Content-Type if it was not specified
+func (r *Request) responseType() string {
+	if r.ResponseType != "" {
+		return r.ResponseType
+	}
+	ct := r.Response.Header.Get("Content-Type")
+	if mt, _, err := mime.ParseMediaType(ct); err == nil && mt == "application/x-www-form-urlencoded" {
+		return "form"
+	}
+	return "json"
+}
+
+// logger returns the Request's Logger, falling back to the
+// package-level Logger, wrapped to prefix each line with the CallID
+// (e.g. "[a1b2c3] Do: started") so that the lines of concurrent
+// calls may be told apart
+func (r *Request) logger() callLogger {
+	base := r.Logger
+	if base == nil {
+		base = Logger
+	}
+	if base.Writer() == ioutil.Discard {
+		// Nothing is logged, so no ID is needed
+		return callLogger{}
+	}
+	return callLogger{base, r.CallID()}
+}
+
+// callLogger logs through a Logger (and so under its lock), adding
+// the ID of the call before each message.  The zero callLogger
+// discards its output.
+type callLogger struct {
+	base *log.Logger
+	id   string
+}
+
+func (l callLogger) Println(v ...interface{}) {
+	if l.base != nil {
+		l.base.Output(2, "["+l.id+"] "+fmt.Sprintln(v...))
+	}
+}
+
+func (l callLogger) Printf(format string, v ...interface{}) {
+	if l.base != nil {
+		l.base.Output(2, "["+l.id+"] "+fmt.Sprintf(format, v...))
+	}
+}
+
+// CallID returns the ID prefixed to the log lines of the most recent
+// call (such as Do), for correlating them with the caller's own logs.
+// Each call has a new ID, generated when first needed.  It is not
+// sent to the server (see RequestID).
+func (r *Request) CallID() string {
+	if r.callID == "" {
+		id, err := randomHex(3)
+		if err != nil {
+			id = "000000"
+		}
+		r.callID = id
+	}
+	return r.callID
+}
+
+// Stats returns metrics about the most recent execution of the
+// Request.  They are populated even if the request failed.
+func (r *Request) Stats() Stats {
+	return r.stats
+}
+
+// context returns the Request's context, defaulting to
+// context.Background()
+func (r *Request) context() context.Context {
+	if r.Context == nil {
+		return context.Background()
+	}
+	return r.Context
+}
+
+// contextWith returns a child of the Request's context, keeping its
+// values and deadline, which is also cancelled when ctx is done and
+// bounded by ctx's deadline
+func (r *Request) contextWith(ctx context.Context) (context.Context, context.CancelFunc) {
+	parent, stop := r.context(), context.CancelFunc(func() {})
+	if deadline, ok := ctx.Deadline(); ok {
+		parent, stop = context.WithDeadline(parent, deadline)
+	}
+	child, cancel := context.WithCancel(parent)
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				cancel()
+			case <-child.Done():
+			}
+		}()
+	}
+	return child, func() {
+		cancel()
+		stop()
+	}
+}
+
 // createHTTPClient generates the http.Client object
 // from default parameters
 func (r *Request) createHTTPClient() {
-	Logger.Println("createHTTPClient: started")
+	r.logger().Println("createHTTPClient: started")
 
 	// Create transport for the request
-	Logger.Println("Creating http.Transport")
-	dial := timeoutDialer(r.Timeout)
-	transport := http.Transport{
-		Dial: dial,
+	transport := r.Transport
+	if transport == nil {
+		transport = DefaultTransport
+	}
+	r.ownTransport = transport == nil
+	if transport == nil {
+		transport = r.newTransport()
 	}
 
 	// Create Client
-	Logger.Println("Creating http.Client")
+	r.logger().Println("Creating http.Client")
 	r.Client = http.Client{
-		Transport: &transport,
+		Transport:     transport,
+		CheckRedirect: r.checkRedirect,
+	}
+	r.logger().Println("createHTTPClient: completed")
+}
+
+// newTransport builds a transport honoring the Timeout, KeepAlive,
+// DialContext, Proxy and TransportConfig
+func (r *Request) newTransport() *http.Transport {
+	r.logger().Println("Creating http.Transport")
+	dial := r.DialContext
+	if dial == nil {
+		dial = timeoutDialer(r.Timeout, r.keepAlive())
+	}
+	return &http.Transport{
+		DialContext: dial,
+
+		// A custom Dial disables HTTP/2 unless explicitly requested
+		ForceAttemptHTTP2: true,
+
+		// Wait for the server to accept an Expect: 100-continue request
+		ExpectContinueTimeout: 1 * time.Second,
+
+		// The transport sends Proxy-Authorization from the URL's credentials
+		Proxy: proxyFunc(r.proxyURL()),
+
+		// An empty AcceptEncodings disables transparent gzip
+		DisableCompression: r.AcceptEncodings != nil && len(r.AcceptEncodings) == 0,
+
+		MaxIdleConns:        r.TransportConfig.MaxIdleConns,
+		MaxIdleConnsPerHost: r.TransportConfig.MaxIdleConnsPerHost,
+		IdleConnTimeout:     r.TransportConfig.IdleConnTimeout,
 	}
-	Logger.Println("createHTTPClient: completed")
 }
 
 // createHTTPRequest generates the actual http.Request object
 // from default parameters
 func (r *Request) createHTTPRequest() Error {
-	Logger.Println("createHTTPRequest: started")
+	r.logger().Println("createHTTPRequest: started")
+	// Resolve the URL
+	u, err := r.resolveURL()
+	if err != nil {
+		r.logger().Println("Failed to resolve URL:", err)
+		return BaseError{0, "Error", err}
+	}
+
 	// Create the new request
-	var err error
-	r.Request, err = http.NewRequest(r.Method, r.Url, r.RequestReader)
+	r.Request, err = http.NewRequestWithContext(r.context(), r.wireMethod(), u.String(), r.RequestReader)
 	if err != nil {
-		Logger.Println("Failed to create request:", err)
+		r.logger().Println("Failed to create request:", err)
 		return BaseError{0, "Error", err}
 	}
+	if r.ContentLength > 0 {
+		r.Request.ContentLength = r.ContentLength
+	}
+	if r.TraceTimings {
+		r.installTrace()
+	}
+
+	// Attach query parameters
+	err = r.encodeQuery()
+	if err != nil {
+		r.logger().Println("Failed to encode query:", err)
+		return BaseError{0, "Encoding Error", err}
+	}
 
-	Logger.Println("createHTTPRequest: completed")
+	r.logger().Println("createHTTPRequest: completed")
 	return nil
 }
 
+// setAcceptEncoding advertises the AcceptEncodings, if set.  Sending
+// an Accept-Encoding disables the transport's transparent gzip, the
+// response instead being decoded by DecodeResponse.  An empty
+// AcceptEncodings sends none, relying on the built transport's
+// DisableCompression; a shared transport cannot be so configured, so
+// for one "identity" is sent instead.
+func (r *Request) setAcceptEncoding() {
+	if r.AcceptEncodings == nil {
+		return
+	}
+	if len(r.AcceptEncodings) > 0 {
+		r.Request.Header.Set("Accept-Encoding", strings.Join(r.AcceptEncodings, ", "))
+		return
+	}
+	if t, ok := r.Client.Transport.(*http.Transport); ok && t.DisableCompression {
+		r.Request.Header.Del("Accept-Encoding")
+		return
+	}
+	r.Request.Header.Set("Accept-Encoding", "identity")
+}
+
+// wireMethod returns the method to send: the Method, or POST if it
+// is to be tunneled with MethodOverride
+func (r *Request) wireMethod() string {
+	if !r.MethodOverride {
+		return r.Method
+	}
+	switch strings.ToUpper(r.Method) {
+	case "GET", "HEAD", "POST":
+		return r.Method
+	}
+	return "POST"
+}
+
+// FinalURL returns the URL of the built request, after BaseURL
+// resolution and query parameters have been applied but before any
+// redirects (see ResponseURL).  It returns the empty string if the
+// request has not yet been built.
+func (r *Request) FinalURL() string {
+	if r.Request == nil {
+		return ""
+	}
+	return r.Request.URL.String()
+}
+
+// encodeQuery merges the QueryParameters and QueryStruct into
+// the query string of the Request's URL, then appends the
+// OrderedQuery.  A RawQuery replaces all of them.
+func (r *Request) encodeQuery() error {
+	if r.RawQuery != "" {
+		r.logger().Println("Using raw query string")
+		r.Request.URL.RawQuery = r.RawQuery
+		return nil
+	}
+
+	if len(r.QueryParameters) > 0 || r.QueryStruct != nil {
+		q := r.Request.URL.Query()
+		for k, v := range r.QueryParameters {
+			q.Set(k, v)
+		}
+		if r.QueryStruct != nil {
+			r.logger().Printf("Encoding QueryStruct (%+v) to query string", r.QueryStruct)
+			v, err := structToVals(r.QueryStruct, queryTagKeys, r.logger())
+			if err != nil {
+				return err
+			}
+			for k, vals := range v {
+				q[k] = vals
+			}
+		}
+		r.Request.URL.RawQuery = q.Encode()
+	}
+
+	// Append the ordered parameters as given, without sorting
+	if len(r.OrderedQuery) > 0 {
+		var b strings.Builder
+		b.WriteString(r.Request.URL.RawQuery)
+		for _, p := range r.OrderedQuery {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(p.Key))
+			b.WriteByte('=')
+			b.WriteString(url.QueryEscape(p.Value))
+		}
+		r.Request.URL.RawQuery = b.String()
+	}
+	return nil
+}
+
+// AddQuery appends a parameter to the OrderedQuery, so that it is
+// sent in the order added
+func (r *Request) AddQuery(key string, value string) *Request {
+	r.OrderedQuery = append(r.OrderedQuery, QueryParam{key, value})
+	return r
+}
+
 // Get is a shorthand MakeRequest with method = "GET"
-func Get(url string, auth Auth, ret interface{}) Error {
+func Get(url string, auth Auth, ret interface{}, opts ...Option) Error {
 	r := NewRequest("GET", url, auth)
 	r.ResponseBody = ret
 	//r.Request.Header.Set("Accept", "application/json")
+	r.apply(opts)
 	return r.Do()
 }
 
+// GetString is a shorthand MakeRequest with method "GET", returning
+// the response body as a string rather than decoding it
+func GetString(url string, auth Auth, opts ...Option) (string, Error) {
+	r := NewRequest("GET", url, auth)
+	r.SkipDecode = true
+	r.apply(opts)
+	if err := r.Do(); err != nil {
+		return "", err
+	}
+	return r.ResponseString(), nil
+}
+
 // Post is a shorthand MakeRequest with method "POST"
-func Post(url string, auth Auth, req interface{}, ret interface{}) Error {
+func Post(url string, auth Auth, req interface{}, ret interface{}, opts ...Option) Error {
 	r := NewRequest("POST", url, auth)
 	r.RequestBody = req
 	r.ResponseBody = ret
 	//r.Request.Header.Set("Accept", "application/json")
+	r.apply(opts)
 	return r.Do()
 }
 
 // PostForm is a shorthand MakeRequest with method "POST" with form encoding
-func PostForm(url string, auth Auth, req interface{}, ret interface{}) Error {
+func PostForm(url string, auth Auth, req interface{}, ret interface{}, opts ...Option) Error {
 	r := NewRequest("POST", url, auth)
 	r.RequestBody = req
 	r.ResponseBody = ret
 	r.RequestType = "form"
 	//r.Request.Header.Set("Accept", "application/json")
+	r.apply(opts)
 	return r.Do()
 }
 
 // Put is a shorthand MakeRequest with method "PUT"
-func Put(url string, auth Auth, req interface{}, ret interface{}) Error {
+func Put(url string, auth Auth, req interface{}, ret interface{}, opts ...Option) Error {
 	r := NewRequest("PUT", url, auth)
 	r.RequestBody = req
 	r.ResponseBody = ret
 	//r.Request.Header.Set("Accept", "application/json")
+	r.apply(opts)
 	return r.Do()
 }
 
 // Delete is a shorthand MakeRequest with method "DELETE"
-func Delete(url string, auth Auth, req interface{}, ret interface{}) Error {
+func Delete(url string, auth Auth, req interface{}, ret interface{}, opts ...Option) Error {
 	r := NewRequest("DELETE", url, auth)
 	r.RequestBody = req
 	r.ResponseBody = ret
 	//r.Request.Header.Set("Accept", "application/json")
+	r.apply(opts)
+	return r.Do()
+}
+
+// DeleteQuery is a shorthand MakeRequest with method "DELETE",
+// identifying the resource by query parameters and sending no body
+func DeleteQuery(url string, auth Auth, query map[string]string, ret interface{}, opts ...Option) Error {
+	r := NewRequest("DELETE", url, auth)
+	r.QueryParameters = query
+	r.ResponseBody = ret
+	r.apply(opts)
 	return r.Do()
 }
 
 // Patch is a shorthand MakeRequest with method "PATCH"
-func Patch(url string, auth Auth, req interface{}, ret interface{}) Error {
+func Patch(url string, auth Auth, req interface{}, ret interface{}, opts ...Option) Error {
 	r := NewRequest("PATCH", url, auth)
 	r.RequestBody = req
 	r.ResponseBody = ret
 	//r.Request.Header.Set("Accept", "application/json")
+	r.apply(opts)
+	return r.Do()
+}
+
+// MergePatch is a shorthand MakeRequest with method "PATCH" and a
+// JSON Merge Patch (RFC 7386) body
+func MergePatch(url string, auth Auth, req interface{}, ret interface{}, opts ...Option) Error {
+	r := NewRequest("PATCH", url, auth)
+	r.RequestBody = req
+	r.ResponseBody = ret
+	r.RequestType = "merge-patch"
+	r.apply(opts)
+	return r.Do()
+}
+
+// JSONPatch is a shorthand MakeRequest with method "PATCH" and a
+// JSON Patch (RFC 6902) body
+func JSONPatch(url string, auth Auth, req interface{}, ret interface{}, opts ...Option) Error {
+	r := NewRequest("PATCH", url, auth)
+	r.RequestBody = req
+	r.ResponseBody = ret
+	r.RequestType = "json-patch"
+	r.apply(opts)
 	return r.Do()
 }
 
 // timeoutDialer is a wrapper function which returns a customized
-// Dial function with a built-in timer for the provided timeout
-// duration
-func timeoutDialer(timeout time.Duration) func(network, addr string) (net.Conn, error) {
-	return func(network string, address string) (net.Conn, error) {
-		return net.DialTimeout(network, address, timeout)
+// DialContext function with a built-in timer for the provided timeout
+// duration and the given keep-alive period.  Dialing with the
+// request's context allows connection tracing and cancellation.
+func timeoutDialer(timeout time.Duration, keepAlive time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: keepAlive}
+	return dialer.DialContext
+}
+
+// proxyFunc returns a transport Proxy function for the given proxy
+// URL, or nil (no proxy) if there is none
+func proxyFunc(u *url.URL, err error) func(*http.Request) (*url.URL, error) {
+	if u == nil || err != nil {
+		return nil
+	}
+	return http.ProxyURL(u)
+}
+
+// keepAlive returns the keep-alive period for dialed connections
+func (r *Request) keepAlive() time.Duration {
+	if r.KeepAlive == 0 {
+		return defaultKeepAlive
 	}
+	return r.KeepAlive
 }
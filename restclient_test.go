@@ -1,8 +1,21 @@
 package restclient
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -50,6 +63,16 @@ func TestCreateClient(t *testing.T) {
 	req.createHTTPClient()
 	assert.NotNil(req.Client)
 	assert.NotNil(req.Client.Transport)
+	assert.True(req.Client.Transport.(*http.Transport).ForceAttemptHTTP2, "HTTP/2 should be negotiated over TLS")
+	assert.Equal(30*time.Second, req.keepAlive())
+	req.KeepAlive = time.Minute
+	assert.Equal(time.Minute, req.keepAlive())
+
+	req.TransportConfig = TransportConfig{MaxIdleConnsPerHost: 100, IdleConnTimeout: time.Minute}
+	req.createHTTPClient()
+	transport := req.Client.Transport.(*http.Transport)
+	assert.Equal(100, transport.MaxIdleConnsPerHost)
+	assert.Equal(time.Minute, transport.IdleConnTimeout)
 }
 
 type TestStructRequest struct {
@@ -69,6 +92,12 @@ func TestEncodeRequest(t *testing.T) {
 	err := req.EncodeRequestBody()
 	assert.Nil(err)
 	assert.NotNil(req.RequestReader)
+	assert.Equal(`{"variable":"hi"}`, string(req.RequestRaw))
+
+	req.RequestType = "form"
+	err = req.EncodeRequestBody()
+	assert.Nil(err)
+	assert.Equal("variable=hi", string(req.RequestRaw))
 }
 
 /*
@@ -83,6 +112,14 @@ func TestProcessStatusCode(t *testing.T) {
 	req.Response.StatusCode = 404
 	err := req.ProcessStatusCode()
 	assert.NotNil(err)
+	req.Response.StatusCode = 401
+	err = req.ProcessStatusCode()
+	_, ok := err.(UnauthorizedError)
+	assert.True(ok, "401 should produce an UnauthorizedError")
+	req.Response.StatusCode = 403
+	err = req.ProcessStatusCode()
+	_, ok = err.(ForbiddenError)
+	assert.True(ok, "403 should produce a ForbiddenError")
 	req.Response.StatusCode = 450
 	err = req.ProcessStatusCode()
 	assert.NotNil(err)
@@ -93,3 +130,1247 @@ func TestProcessStatusCode(t *testing.T) {
 	err = req.ProcessStatusCode()
 	assert.Nil(err)
 }
+
+type TestQueryParams struct {
+	Search string `query:"q"`
+	Page   int    `form:"page"`
+	Limit  int    `json:"limit,omitempty"`
+	Secret string `query:"-"`
+}
+
+func TestQueryStruct(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequest("GET", "http://url.com/items?sort=asc", *auth)
+	req.QueryParameters = map[string]string{"filter": "open"}
+	req.QueryStruct = &TestQueryParams{Search: "foo bar", Page: 2, Secret: "shh"}
+	err := req.createHTTPRequest()
+	assert.Nil(err)
+	q := req.Request.URL.Query()
+	assert.Equal("asc", q.Get("sort"), "Existing query should be preserved")
+	assert.Equal("open", q.Get("filter"))
+	assert.Equal("foo bar", q.Get("q"))
+	assert.Equal("2", q.Get("page"))
+	_, hasLimit := q["limit"]
+	assert.False(hasLimit, "Zero value with omitempty should be omitted")
+	assert.Empty(q.Get("Secret"))
+}
+
+type countingLimiter struct {
+	calls int
+	err   error
+}
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.calls++
+	return l.err
+}
+
+func TestRateLimiter(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"variable":"hi"}`))
+	}))
+	defer ts.Close()
+
+	limiter := &countingLimiter{}
+	ret := TestStructRequest{}
+	req := NewRequest("GET", ts.URL, *auth)
+	req.ResponseBody = &ret
+	req.RateLimiter = limiter
+	assert.Nil(req.Do())
+	assert.Equal(1, limiter.calls)
+	assert.Equal("hi", ret.Variable)
+
+	limiter.err = errors.New("limited")
+	err := req.Do()
+	assert.NotNil(err)
+	assert.Equal(2, limiter.calls)
+}
+
+func TestSkipDecode(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a,b,c\n1,2,3\n"))
+	}))
+	defer ts.Close()
+
+	ret := TestStructRequest{}
+	req := NewRequest("GET", ts.URL, *auth)
+	req.ResponseBody = &ret
+	req.SkipDecode = true
+	assert.Nil(req.Do())
+	assert.Equal("a,b,c\n1,2,3\n", string(req.ResponseRaw))
+	assert.Empty(ret.Variable)
+}
+
+func TestRequireBody(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/none" {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	// Lenient by default
+	req := NewRequest("GET", ts.URL, *auth)
+	req.ResponseBody = new(TestThing)
+	assert.Nil(req.Do())
+
+	req.RequireBody = true
+	err := req.Do()
+	_, ok := err.(EmptyResponseError)
+	assert.True(ok, "an empty body should produce an EmptyResponseError")
+	assert.Equal(200, err.Code())
+
+	// No body is expected without a target, or for a 204
+	req.ResponseBody = nil
+	assert.Nil(req.Do())
+	req = NewRequest("GET", ts.URL+"/none", *auth)
+	req.ResponseBody = new(TestThing)
+	req.RequireBody = true
+	assert.Nil(req.Do())
+}
+
+func TestStats(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"variable":"hi"}`))
+	}))
+	defer ts.Close()
+
+	req := NewRequest("GET", ts.URL, *auth)
+	req.ResponseBody = &TestStructRequest{}
+	assert.Nil(req.Do())
+	stats := req.Stats()
+	assert.Equal(200, stats.StatusCode)
+	assert.Equal(int64(17), stats.BytesRead)
+	assert.True(stats.Duration > 0)
+
+	req = NewRequest("GET", ts.URL+"/missing", *auth)
+	assert.NotNil(req.Do())
+	assert.Equal(404, req.Stats().StatusCode)
+	assert.True(req.Stats().Duration > 0)
+}
+
+type TestTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `form:"scope"`
+}
+
+func TestDecodeFormResponse(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+		w.Write([]byte("access_token=abc123&expires_in=3600&scope=read+write"))
+	}))
+	defer ts.Close()
+
+	ret := TestTokenResponse{}
+	err := Get(ts.URL, *auth, &ret)
+	assert.Nil(err)
+	assert.Equal("abc123", ret.AccessToken)
+	assert.Equal(3600, ret.ExpiresIn)
+	assert.Equal("read write", ret.Scope)
+}
+
+/*
+	Verify the digest computation against the example in RFC 2617
+*/
+func TestDigestAuthorization(t *testing.T) {
+	assert := assert.New(t)
+	c, err := parseDigestChallenge(`Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`)
+	assert.Nil(err)
+	assert.Equal("testrealm@host.com", c.Realm)
+	assert.Equal("auth,auth-int", c.Qop)
+	assert.Equal("5ccc069c403ebaf9f0171e9517f40e41", c.Opaque)
+
+	h, err := c.authorization(Auth{"Mufasa", "Circle Of Life"}, "GET", "/dir/index.html", "0a4f113b")
+	assert.Nil(err)
+	assert.Contains(h, `response="6629fae49393a05397450978507c4ef1"`)
+	assert.Contains(h, `opaque="5ccc069c403ebaf9f0171e9517f40e41"`)
+	assert.Contains(h, `qop=auth, nc=00000001, cnonce="0a4f113b"`)
+}
+
+func TestDigestChallengeFlow(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.Header().Set("WWW-Authenticate", `Digest realm="test", nonce="abc", qop="auth"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"variable":"hi"}`))
+	}))
+	defer ts.Close()
+
+	ret := TestStructRequest{}
+	req := NewRequest("GET", ts.URL, *auth)
+	req.AuthType = "digest"
+	req.ResponseBody = &ret
+	assert.Nil(req.Do())
+	assert.Contains(req.Request.Header.Get("Authorization"), `Digest username="edward"`)
+	assert.Equal("hi", ret.Variable)
+}
+
+func TestAPIKey(t *testing.T) {
+	assert := assert.New(t)
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer ts.Close()
+
+	req := NewRequestBasic("GET", ts.URL)
+	req.APIKeyValue = "secret"
+	assert.Nil(req.Do())
+	assert.Equal("secret", got.Get("X-API-Key"))
+
+	req = NewRequestBasic("GET", ts.URL)
+	req.APIKeyHeader = "Api-Token"
+	req.APIKeyValue = "other"
+	assert.Nil(req.Do())
+	assert.Equal("other", got.Get("Api-Token"))
+	assert.Empty(got.Get("X-API-Key"))
+}
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequest("GET", "http://url.com/path", *auth)
+	assert.Nil(req.Validate())
+
+	req = NewRequest("", "http://url.com/path", *auth)
+	assert.NotNil(req.Validate(), "Empty method should be rejected")
+	req = NewRequest("get", "http://url.com/path", *auth)
+	assert.NotNil(req.Validate(), "Lowercase method should be rejected")
+	req = NewRequest("GE T", "http://url.com/path", *auth)
+	assert.NotNil(req.Validate(), "Invalid method should be rejected")
+	req = NewRequest("GET", "url.com", *auth)
+	assert.NotNil(req.Validate(), "Relative URL should be rejected")
+	req = NewRequest("GET", "http://url.com/%zz", *auth)
+	assert.NotNil(req.Validate(), "Unparseable URL should be rejected")
+}
+
+func TestCompressRequest(t *testing.T) {
+	assert := assert.New(t)
+	var encoding string
+	var got TestStructRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(zr).Decode(&got)
+	}))
+	defer ts.Close()
+
+	req := NewRequest("POST", ts.URL, *auth)
+	req.RequestBody = TestStructRequest{"compressed"}
+	req.CompressRequest = true
+	assert.Nil(req.Do())
+	assert.Equal("gzip", encoding)
+	assert.Equal("compressed", got.Variable)
+}
+
+type TestThing struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestDecodeMapAndSlice(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/list":
+			w.Write([]byte(`[{"id":1,"name":"one"},{"id":2,"name":"two"}]`))
+		case "/object":
+			w.Write([]byte(`{"id":3,"tags":["a","b"]}`))
+		case "/form":
+			w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+			w.Write([]byte(`a=1&b=2`))
+		}
+	}))
+	defer ts.Close()
+
+	var things []TestThing
+	assert.Nil(Get(ts.URL+"/list", *auth, &things))
+	assert.Equal([]TestThing{{1, "one"}, {2, "two"}}, things)
+
+	var obj map[string]interface{}
+	assert.Nil(Get(ts.URL+"/object", *auth, &obj))
+	assert.Equal(float64(3), obj["id"])
+	assert.Equal([]interface{}{"a", "b"}, obj["tags"])
+
+	var form map[string]string
+	assert.Nil(Get(ts.URL+"/form", *auth, &form))
+	assert.Equal(map[string]string{"a": "1", "b": "2"}, form)
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"variable":"0123456789"}`))
+	}))
+	defer ts.Close()
+
+	req := NewRequest("GET", ts.URL, *auth)
+	req.ResponseBody = &TestStructRequest{}
+	req.MaxResponseBytes = 10
+	err := req.Do()
+	assert.NotNil(err)
+	tooLarge, ok := err.(ResponseTooLargeError)
+	assert.True(ok, "Error should be a ResponseTooLargeError")
+	assert.Equal(int64(10), tooLarge.Limit)
+
+	req.MaxResponseBytes = 25
+	assert.Nil(req.Do(), "Body exactly at the limit should be accepted")
+}
+
+func TestValidateResponse(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"variable":"error"}`))
+	}))
+	defer ts.Close()
+
+	ret := TestStructRequest{}
+	req := NewRequest("GET", ts.URL, *auth)
+	req.ResponseBody = &ret
+	req.ValidateResponse = func(r *Request) error {
+		if r.ResponseBody.(*TestStructRequest).Variable == "error" {
+			return errors.New("server signalled an error")
+		}
+		return nil
+	}
+	err := req.Do()
+	assert.NotNil(err)
+	assert.Equal(200, err.Code())
+	assert.Equal("server signalled an error", err.Error())
+}
+
+func TestContentLength(t *testing.T) {
+	assert := assert.New(t)
+	var length int64
+	var encoding []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		length = r.ContentLength
+		encoding = r.TransferEncoding
+	}))
+	defer ts.Close()
+
+	// A reader of unknown length is sent chunked
+	req := NewRequest("POST", ts.URL, *auth)
+	req.RequestReader = io.MultiReader(strings.NewReader("hello"))
+	assert.Nil(req.Do())
+	assert.Equal([]string{"chunked"}, encoding)
+
+	req = NewRequest("POST", ts.URL, *auth)
+	req.RequestReader = io.MultiReader(strings.NewReader("hello"))
+	req.ContentLength = 5
+	assert.Nil(req.Do())
+	assert.Equal(int64(5), length)
+	assert.Empty(encoding)
+}
+
+func TestStrictDecode(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"variable":"hi","unexpected":true}`))
+	}))
+	defer ts.Close()
+
+	req := NewRequest("GET", ts.URL, *auth)
+	req.ResponseBody = &TestStructRequest{}
+	assert.Nil(req.Do(), "Unknown fields should be ignored by default")
+
+	req.StrictDecode = true
+	assert.NotNil(req.Do(), "Unknown fields should fail strict decoding")
+}
+
+func TestUseNumber(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":1234567890123456789}`))
+	}))
+	defer ts.Close()
+
+	var ret map[string]interface{}
+	req := NewRequest("GET", ts.URL, *auth)
+	req.ResponseBody = &ret
+	req.UseNumber = true
+	assert.Nil(req.Do())
+	assert.Equal(json.Number("1234567890123456789"), ret["id"])
+}
+
+func TestEncodeError(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequest("POST", "http://url.com/items", *auth)
+	req.RequestBody = make(chan int)
+	err := req.EncodeRequestBody()
+	assert.NotNil(err)
+	encErr, ok := err.(EncodeError)
+	assert.True(ok, "Error should be an EncodeError")
+	assert.Equal("POST", encErr.Method)
+	assert.Equal("http://url.com/items", encErr.Url)
+	assert.Equal("json", encErr.RequestType)
+	assert.Contains(err.Error(), "POST http://url.com/items")
+}
+
+func TestPatchTypes(t *testing.T) {
+	assert := assert.New(t)
+	var contentType string
+	var body []interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer ts.Close()
+
+	assert.Nil(MergePatch(ts.URL, *auth, TestStructRequest{"hi"}, nil))
+	assert.Equal("application/merge-patch+json", contentType)
+
+	ops := []map[string]string{{"op": "remove", "path": "/variable"}}
+	assert.Nil(JSONPatch(ts.URL, *auth, ops, nil))
+	assert.Equal("application/json-patch+json", contentType)
+	assert.Equal(1, len(body))
+}
+
+func TestTransportErrors(t *testing.T) {
+	assert := assert.New(t)
+	block := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(block)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := NewRequest("GET", ts.URL, *auth)
+	req.Context = ctx
+	go cancel()
+	_, ok := req.Do().(CanceledError)
+	assert.True(ok, "Canceled context should produce a CanceledError")
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req = NewRequest("GET", ts.URL, *auth)
+	req.Context = ctx
+	_, ok = req.Do().(TimeoutError)
+	assert.True(ok, "Exceeded deadline should produce a TimeoutError")
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	req = NewRequest("GET", closed.URL, *auth)
+	_, ok = req.Do().(ConnectionError)
+	assert.True(ok, "Refused connection should produce a ConnectionError")
+}
+
+func TestRawRequestBody(t *testing.T) {
+	assert := assert.New(t)
+	var got string
+	var contentType string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+		contentType = r.Header.Get("Content-Type")
+	}))
+	defer ts.Close()
+
+	req := NewRequest("POST", ts.URL, *auth)
+	req.RequestBody = `{"query":"{ viewer { login } }"}`
+	assert.Nil(req.Do())
+	assert.Equal(`{"query":"{ viewer { login } }"}`, got)
+
+	req = NewRequest("POST", ts.URL, *auth)
+	req.RequestBody = []byte("<xml/>")
+	req.SetHeader("Content-Type", "application/xml")
+	assert.Nil(req.Do())
+	assert.Equal("<xml/>", got)
+	assert.Equal("application/xml", contentType)
+}
+
+func TestTraceTimings(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	req := NewRequest("GET", ts.URL, *auth)
+	assert.Nil(req.Do())
+	assert.Equal(Timings{}, req.Timings(), "Timings should not be recorded by default")
+
+	req.TraceTimings = true
+	assert.Nil(req.Do())
+	timings := req.Timings()
+	assert.True(timings.Connect > 0)
+	assert.True(timings.TimeToFirstByte >= 5*time.Millisecond)
+}
+
+func TestRequestLogger(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	req := NewRequest("GET", ts.URL, *auth)
+	req.Logger = log.New(&buf, "", 0)
+	assert.Nil(req.Do())
+	assert.Contains(buf.String(), "Do: started")
+	assert.Contains(buf.String(), "Do: completed")
+}
+
+func TestCallID(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	req := NewRequest("GET", ts.URL, *auth)
+	req.Logger = log.New(&buf, "api ", 0)
+	assert.Nil(req.Do())
+	id := req.CallID()
+	assert.Len(id, 6)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		assert.True(strings.HasPrefix(line, "api ["+id+"] "), line)
+	}
+
+	// Each call has its own ID
+	buf.Reset()
+	assert.Nil(req.Do())
+	assert.NotEqual(id, req.CallID())
+	assert.True(strings.HasPrefix(buf.String(), "api ["+req.CallID()+"] Do: started"))
+
+	// Form encoding logs through the Request's Logger too
+	buf.Reset()
+	req = NewRequest("POST", ts.URL, *auth)
+	req.Logger = log.New(&buf, "api ", 0)
+	req.RequestType = "form"
+	req.RequestBody = &struct {
+		Skipped map[string]int `form:"skipped"`
+	}{map[string]int{"a": 1}}
+	assert.Nil(req.Do())
+	assert.Contains(buf.String(), "api ["+req.CallID()+"] Ignoring field Skipped")
+}
+
+func TestCallIDConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	// Requests sharing a Logger write through its lock
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := NewRequest("GET", ts.URL, *auth)
+			req.Logger = logger
+			assert.Nil(req.Do())
+		}()
+	}
+	wg.Wait()
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		assert.Regexp(`^\[[0-9a-f]{6}\] `, line)
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	assert := assert.New(t)
+	var sent []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		sent = strings.Split(strings.TrimSpace(string(b)), "\n")
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{\"id\":1,\"name\":\"one\"}\n\n{\"id\":2,\"name\":\"two\"}\n"))
+	}))
+	defer ts.Close()
+
+	var got []TestThing
+	req := NewRequest("POST", ts.URL, *auth)
+	req.RequestType = "ndjson"
+	req.RequestBody = []TestThing{{1, "a"}, {2, "b"}}
+	req.LineHandler = func(line json.RawMessage) error {
+		var thing TestThing
+		err := json.Unmarshal(line, &thing)
+		got = append(got, thing)
+		return err
+	}
+	assert.Nil(req.Do())
+	assert.Equal([]string{`{"id":1,"name":"a"}`, `{"id":2,"name":"b"}`}, sent)
+	assert.Equal([]TestThing{{1, "one"}, {2, "two"}}, got)
+	assert.Equal(int64(45), req.Stats().BytesRead)
+}
+
+func TestSwitchingProtocols(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\nhello")
+		buf.Flush()
+	}))
+	defer ts.Close()
+
+	req := NewRequest("GET", ts.URL, *auth)
+	req.SetHeader("Connection", "Upgrade")
+	req.SetHeader("Upgrade", "websocket")
+	err := req.Do()
+	upgrade, ok := err.(SwitchingProtocolsError)
+	assert.True(ok, "101 should produce a SwitchingProtocolsError")
+	assert.Equal("websocket", upgrade.Protocol)
+	assert.NotNil(upgrade.Conn)
+	b := make([]byte, 5)
+	_, rerr := io.ReadFull(upgrade.Conn, b)
+	assert.Nil(rerr, "The upgraded connection should remain open")
+	assert.Equal("hello", string(b))
+	upgrade.Conn.Close()
+}
+
+func TestMethodOverride(t *testing.T) {
+	assert := assert.New(t)
+	var method, override, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		override = r.Header.Get("X-HTTP-Method-Override")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer ts.Close()
+
+	req := NewRequest("PATCH", ts.URL, *auth)
+	req.MethodOverride = true
+	req.RequestBody = TestStructRequest{"hi"}
+	assert.Nil(req.Do())
+	assert.Equal("POST", method)
+	assert.Equal("PATCH", override)
+	assert.Equal(`{"variable":"hi"}`, body)
+
+	// GET, HEAD and POST are sent as they are
+	req = NewRequest("GET", ts.URL, *auth)
+	req.MethodOverride = true
+	assert.Nil(req.Do())
+	assert.Equal("GET", method)
+	assert.Empty(override)
+}
+
+func TestExpectContinue(t *testing.T) {
+	assert := assert.New(t)
+	var bodyRead bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("100-continue", r.Header.Get("Expect"))
+		if r.Header.Get("Authorization") == "" {
+			// Reject without reading the body, so no 100 Continue is sent
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.ReadAll(r.Body)
+		bodyRead = true
+	}))
+	defer ts.Close()
+
+	req := NewRequestBasic("PUT", ts.URL)
+	req.RequestBody = strings.Repeat("x", 1<<20)
+	req.ExpectContinue = true
+	_, ok := req.Do().(UnauthorizedError)
+	assert.True(ok)
+	assert.False(bodyRead)
+
+	req = NewRequest("PUT", ts.URL, *auth)
+	req.RequestBody = strings.Repeat("x", 1<<20)
+	req.ExpectContinue = true
+	assert.Nil(req.Do())
+	assert.True(bodyRead)
+}
+
+func TestClone(t *testing.T) {
+	assert := assert.New(t)
+	tmpl := NewRequest("GET", "http://url.com", *auth)
+	tmpl.SetHeader("X-Common", "1")
+	tmpl.QueryParameters = map[string]string{"a": "1"}
+	tmpl.StatusBodies = map[string]interface{}{"404": new(TestErrorMessage)}
+	assert.Nil(tmpl.createHTTPRequest())
+
+	c := tmpl.Clone()
+	AuthTester(t, tmpl.Auth, c.Auth)
+	assert.Nil(c.Request)
+	c.SetHeader("X-Common", "2")
+	c.QueryParameters["a"] = "2"
+	c.StatusBodies["409"] = new(TestErrorMessage)
+	assert.Equal("1", tmpl.Headers.Get("X-Common"), "Headers should not be shared")
+	assert.Equal("1", tmpl.QueryParameters["a"], "QueryParameters should not be shared")
+	assert.Len(tmpl.StatusBodies, 1, "StatusBodies should not be shared")
+	assert.NotNil(tmpl.Request)
+}
+
+func TestReset(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer ts.Close()
+
+	req := NewRequest("POST", ts.URL, *auth)
+	req.SetHeader("X-Common", "1")
+	req.RequestBody = TestStructRequest{"hi"}
+	req.ResponseBody = new(TestThing)
+	assert.Nil(req.Do())
+	assert.NotNil(req.Response)
+
+	req.Reset()
+	assert.Nil(req.Request)
+	assert.Nil(req.Response)
+	assert.Nil(req.RequestReader)
+	assert.Nil(req.ResponseRaw)
+	assert.Equal(Stats{}, req.Stats())
+	assert.Equal("POST", req.Method)
+	assert.Equal("1", req.Headers.Get("X-Common"))
+	AuthTester(t, *auth, req.Auth)
+	assert.Nil(req.Do(), "a reset request should be reusable")
+}
+
+func TestClassifyStatus(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequest("GET", "url.com", *auth)
+	req.Response = new(http.Response)
+	req.ClassifyStatus = func(code int) error {
+		if code == 422 || code < 300 {
+			return nil
+		}
+		return errors.New("unexpected")
+	}
+	req.Response.StatusCode = 422
+	assert.Nil(req.ProcessStatusCode())
+	req.Response.StatusCode = 200
+	assert.Nil(req.ProcessStatusCode())
+	req.Response.StatusCode = 302
+	err := req.ProcessStatusCode()
+	assert.NotNil(err)
+	assert.Equal(302, err.Code())
+}
+
+func TestBuild(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequest("POST", "http://url.com/items", *auth)
+	req.QueryParameters = map[string]string{"a": "1"}
+	req.RequestBody = TestStructRequest{"hi"}
+	httpReq, err := req.Build()
+	assert.Nil(err)
+	assert.Equal("POST", httpReq.Method)
+	assert.Equal("http://url.com/items?a=1", httpReq.URL.String())
+	assert.Equal("application/json", httpReq.Header.Get("Content-Type"))
+	user, _, ok := httpReq.BasicAuth()
+	assert.True(ok)
+	assert.Equal(auth.Username, user)
+	body, _ := io.ReadAll(httpReq.Body)
+	assert.Equal(`{"variable":"hi"}`, string(body))
+	assert.Nil(req.Response, "Build should not send the request")
+}
+
+func TestDeleteQuery(t *testing.T) {
+	assert := assert.New(t)
+	var got *http.Request
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+
+	assert.Nil(DeleteQuery(ts.URL+"/items", *auth, map[string]string{"id": "5"}, nil))
+	assert.Equal("DELETE", got.Method)
+	assert.Equal("/items?id=5", got.URL.RequestURI())
+	assert.Empty(body)
+	assert.Equal(int64(0), got.ContentLength)
+	assert.Empty(got.Header.Get("Content-Type"), "a bodiless request should carry no Content-Type")
+}
+
+func TestBodyWriter(t *testing.T) {
+	assert := assert.New(t)
+	lines := make(chan string)
+	var chunked bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunked = len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked"
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}))
+	defer ts.Close()
+
+	req := NewRequest("POST", ts.URL, *auth)
+	req.RequestType = "ndjson"
+	w := req.BodyWriter()
+	go func() {
+		// Each line must arrive before the next is written
+		for _, line := range []string{`{"n":1}`, `{"n":2}`} {
+			io.WriteString(w, line+"\n")
+			assert.Equal(line, <-lines)
+		}
+		w.Close()
+	}()
+	assert.Nil(req.Do())
+	assert.True(chunked)
+	_, open := <-lines
+	assert.False(open)
+}
+
+func TestElementHandler(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":1,"name":"one"}, {"id":2,"name":"two"}]`))
+	}))
+	defer ts.Close()
+
+	var got []TestThing
+	req := NewRequest("GET", ts.URL, *auth)
+	req.NewElement = func() interface{} { return new(TestThing) }
+	req.ElementHandler = func(elem interface{}) error {
+		got = append(got, *elem.(*TestThing))
+		return nil
+	}
+	assert.Nil(req.Do())
+	assert.Equal([]TestThing{{1, "one"}, {2, "two"}}, got)
+
+	ts2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer ts2.Close()
+	req.Url = ts2.URL
+	assert.NotNil(req.Do(), "a non-array response should fail")
+}
+
+func TestDecodeRawMessage(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"type":"thing","data":{"id":1}}`))
+	}))
+	defer ts.Close()
+
+	var raw json.RawMessage
+	req := NewRequest("GET", ts.URL, *auth)
+	req.ResponseBody = &raw
+	assert.Nil(req.Do())
+	assert.Equal(`{"type":"thing","data":{"id":1}}`, string(raw))
+
+	// Defer decoding of a sub-object
+	var envelope struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	req.ResponseBody = &envelope
+	req.StrictDecode = true
+	assert.Nil(req.Do())
+	assert.Equal("thing", envelope.Type)
+	assert.Equal(`{"id":1}`, string(envelope.Data))
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestDefaultTransport(t *testing.T) {
+	assert := assert.New(t)
+	var got *http.Request
+	DefaultTransport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		got = req
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"id":7}`)),
+			Request:    req,
+		}, nil
+	})
+	defer func() { DefaultTransport = nil }()
+
+	ret := new(TestThing)
+	assert.Nil(Get("http://example.invalid/things/7", *auth, ret))
+	assert.Equal(7, ret.ID)
+	assert.Equal("/things/7", got.URL.Path)
+}
+
+type TestErrorMessage struct {
+	Message string `json:"message"`
+}
+
+func TestErrorBody(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"message":"name is required"}`))
+	}))
+	defer ts.Close()
+
+	req := NewRequest("POST", ts.URL, *auth)
+	req.RequestBody = TestStructRequest{""}
+	req.ErrorBody = new(TestErrorMessage)
+	err := req.Do()
+	reqErr, ok := err.(RequestError)
+	assert.True(ok, "a 422 should produce a RequestError")
+	assert.Equal(422, reqErr.Code())
+	assert.Equal("name is required", reqErr.Parsed().(*TestErrorMessage).Message)
+	assert.Equal(`{"message":"name is required"}`, string(req.ResponseRaw))
+
+	// Without an ErrorBody, the raw body is still available
+	req.ErrorBody = nil
+	err = req.Do()
+	assert.Nil(err.(RequestError).Parsed())
+	assert.Equal(`{"message":"name is required"}`, string(req.ResponseRaw))
+}
+
+func TestMaxErrorBodyBytes(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message":"` + strings.Repeat("x", 100) + `"}`))
+	}))
+	defer ts.Close()
+
+	req := NewRequest("GET", ts.URL, *auth)
+	req.ErrorBody = new(TestErrorMessage)
+	err := req.Do()
+	assert.Equal(strings.Repeat("x", 100), err.(RequestError).Parsed().(*TestErrorMessage).Message)
+
+	// Oversized error bodies are not captured, but the error is still classified
+	req.MaxErrorBodyBytes = 50
+	err = req.Do()
+	reqErr, ok := err.(RequestError)
+	assert.True(ok)
+	assert.Nil(reqErr.Parsed())
+	assert.EqualValues(50, req.maxErrorBodyBytes())
+	assert.Equal(`{"message":"`+strings.Repeat("x", 38), string(req.ResponseRaw), "the start of the body should be kept")
+
+	// A smaller MaxResponseBytes also applies
+	req.MaxErrorBodyBytes = 0
+	req.MaxResponseBytes = 10
+	assert.EqualValues(10, req.maxErrorBodyBytes())
+	req.MaxResponseBytes = 1 << 20
+	assert.EqualValues(64<<10, req.maxErrorBodyBytes())
+
+	// An oversized problem document is not mistaken for the previous one
+	large := false
+	ts2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusInternalServerError)
+		if large {
+			w.Write([]byte(`{"title":"new","detail":"` + strings.Repeat("x", 100) + `"}`))
+			return
+		}
+		w.Write([]byte(`{"title":"old","detail":"first call"}`))
+	}))
+	defer ts2.Close()
+	req = NewRequest("GET", ts2.URL, *auth)
+	req.MaxErrorBodyBytes = 50
+	err = req.Do()
+	assert.Contains(err.Error(), "old: first call")
+	large = true
+	err = req.Do()
+	assert.NotContains(err.Error(), "old")
+}
+
+func TestProblemError(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/plain" {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+		if r.URL.Path == "/forbidden" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"title":"No access"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"type":"https://example.com/probs/missing","title":"Not here","status":404,"detail":"Item 5 does not exist","instance":"/items/5","message":"gone"}`))
+	}))
+	defer ts.Close()
+
+	req := NewRequest("GET", ts.URL+"/items/5", *auth)
+	req.ErrorBody = new(TestErrorMessage)
+	err := req.Do()
+	probErr, ok := err.(ProblemError)
+	if assert.True(ok, "a problem+json response should produce a ProblemError") {
+		assert.Equal(404, probErr.Code())
+		assert.Equal("https://example.com/probs/missing", probErr.Type())
+		assert.Equal("Not here", probErr.Title())
+		assert.Equal(404, probErr.ProblemStatus())
+		assert.Equal("Item 5 does not exist", probErr.Detail())
+		assert.Equal("/items/5", probErr.Instance())
+		assert.Equal("Request: Problem: Not here: Item 5 does not exist", probErr.Error())
+		assert.Equal("gone", probErr.Parsed().(*TestErrorMessage).Message)
+	}
+
+	// Problem documents take precedence over the specific errors
+	err = Get(ts.URL+"/forbidden", *auth, nil)
+	probErr, ok = err.(ProblemError)
+	if assert.True(ok, "a problem+json 403 should produce a ProblemError") {
+		assert.Equal(403, probErr.Code())
+		assert.Equal("No access", probErr.Title())
+		assert.Equal("about:blank", probErr.Type())
+	}
+
+	// Other content types keep the existing errors
+	err = Get(ts.URL+"/plain", *auth, nil)
+	_, ok = err.(RequestError)
+	assert.True(ok)
+}
+
+func TestOrderedQuery(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequest("GET", "http://url.com/items?z=1", *auth)
+	req.AddQuery("sig", "a b").AddQuery("b", "2").AddQuery("a", "3")
+	httpReq, err := req.Build()
+	assert.Nil(err)
+	assert.Equal("z=1&sig=a+b&b=2&a=3", httpReq.URL.RawQuery)
+
+	req.QueryParameters = map[string]string{"y": "4"}
+	httpReq, err = req.Build()
+	assert.Nil(err)
+	assert.Equal("y=4&z=1&sig=a+b&b=2&a=3", httpReq.URL.RawQuery)
+}
+
+func TestRawQuery(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequest("GET", "http://url.com/items?z=1", *auth)
+	req.QueryParameters = map[string]string{"a": "1"}
+	req.RawQuery = "b=2;c=3&sig=AbC%2F"
+	httpReq, err := req.Build()
+	assert.Nil(err)
+	assert.Equal("http://url.com/items?b=2;c=3&sig=AbC%2F", httpReq.URL.String())
+}
+
+func TestJSONEncoderOptions(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequest("POST", "http://url.com", *auth)
+	req.RequestBody = TestStructRequest{"<b>&</b>"}
+	b, err := req.encodeJson()
+	assert.Nil(err)
+	assert.Equal(`{"variable":"\u003cb\u003e\u0026\u003c/b\u003e"}`, string(b))
+
+	// The zero value escapes as json.Marshal does
+	b, err = (&Request{RequestBody: req.RequestBody, JSONIndent: "  "}).encodeJson()
+	assert.Nil(err)
+	assert.Equal("{\n  \"variable\": \"\\u003cb\\u003e\\u0026\\u003c/b\\u003e\"\n}", string(b))
+
+	req.JSONDisableHTMLEscape = true
+	b, err = req.encodeJson()
+	assert.Nil(err)
+	assert.Equal(`{"variable":"<b>&</b>"}`, string(b))
+
+	req.JSONIndent = "  "
+	b, err = req.encodeJson()
+	assert.Nil(err)
+	assert.Equal("{\n  \"variable\": \"<b>&</b>\"\n}", string(b))
+}
+
+func TestNoContent(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	req := NewRequest("PUT", ts.URL, *auth)
+	assert.False(req.NoContent())
+	req.RequestBody = TestStructRequest{"hi"}
+	req.ResponseBody = new(TestThing)
+	req.StrictDecode = true
+	assert.Nil(req.Do())
+	assert.True(req.NoContent())
+
+	// Streaming decoders are not run either
+	req.ElementHandler = func(interface{}) error { return nil }
+	assert.Nil(req.Do())
+}
+
+func TestDecodeError(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"id":1}]`))
+	}))
+	defer ts.Close()
+
+	req := NewRequest("GET", ts.URL, *auth)
+	req.ResponseBody = new(TestThing)
+	err := req.Do()
+	derr, ok := err.(DecodeError)
+	assert.True(ok, "a type mismatch should produce a DecodeError")
+	assert.Equal("application/json", derr.ContentType)
+	assert.Equal("*restclient.TestThing", derr.Target)
+	assert.Equal(`[{"id":1}]`, string(derr.Body))
+	assert.Contains(err.Error(), `[{\"id\":1}]`)
+	assert.Equal("Decode Error", err.Message())
+}
+
+func TestDialContext(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	sock := dir + "/api.sock"
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skip("unix sockets unavailable:", err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":3}`))
+	}))
+	ts.Listener = l
+	ts.Start()
+	defer ts.Close()
+
+	ret := new(TestThing)
+	req := NewRequest("GET", "http://unix/things/3", *auth)
+	req.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+	}
+	req.ResponseBody = ret
+	assert.Nil(req.Do())
+	assert.Equal(3, ret.ID)
+}
+
+func TestGetString(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("1.2.3"))
+	}))
+	defer ts.Close()
+
+	version, err := GetString(ts.URL+"/version", *auth)
+	assert.Nil(err)
+	assert.Equal("1.2.3", version)
+
+	_, err = GetString(ts.URL+"/missing", *auth)
+	assert.NotNil(err)
+	assert.Equal(404, err.Code())
+}
+
+func TestStatusBodies(t *testing.T) {
+	assert := assert.New(t)
+	status := http.StatusCreated
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		if status < 300 {
+			w.Write([]byte(`{"id":1,"name":"one"}`))
+			return
+		}
+		w.Write([]byte(`{"message":"bad"}`))
+	}))
+	defer ts.Close()
+
+	created := new(TestThing)
+	failed := new(TestErrorMessage)
+	req := NewRequest("POST", ts.URL, *auth)
+	req.RequestBody = TestStructRequest{"hi"}
+	req.ResponseBody = new(TestErrorMessage)
+	req.StatusBodies = map[string]interface{}{"201": created, "4xx": failed}
+	assert.Nil(req.Do())
+	assert.Equal(TestThing{1, "one"}, *created)
+
+	status = http.StatusConflict
+	err := req.Do()
+	assert.NotNil(err)
+	assert.Equal("bad", failed.Message)
+	assert.Equal(failed, err.(RequestError).Parsed())
+
+	// 401 and 403 responses are decoded too
+	unauthorized := new(TestErrorMessage)
+	req.StatusBodies["401"] = unauthorized
+	status = http.StatusUnauthorized
+	err = req.Do()
+	assert.Equal("bad", unauthorized.Message)
+	assert.Equal(unauthorized, err.(UnauthorizedError).Parsed())
+	assert.Equal(`{"message":"bad"}`, string(req.ResponseRaw))
+
+	*failed = TestErrorMessage{}
+	status = http.StatusForbidden
+	err = req.Do()
+	assert.Equal("bad", failed.Message)
+	assert.Equal(failed, err.(ForbiddenError).Parsed())
+}
+
+func TestRetryUncompressed(t *testing.T) {
+	assert := assert.New(t)
+	var encodings []string
+	var got TestStructRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		if r.Header.Get("Content-Encoding") != "" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			w.Write([]byte(`{"message":"gzip not supported"}`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer ts.Close()
+
+	req := NewRequest("POST", ts.URL, *auth)
+	req.RequestBody = TestStructRequest{"hi"}
+	req.CompressRequest = true
+	req.ErrorBody = new(TestErrorMessage)
+	err := req.Do()
+	umt, ok := err.(UnsupportedMediaTypeError)
+	assert.True(ok, "a 415 should produce an UnsupportedMediaTypeError")
+	assert.Equal("gzip", umt.ContentEncoding)
+	assert.Equal("application/json", umt.ContentType)
+	assert.Equal("gzip not supported", umt.Parsed().(*TestErrorMessage).Message)
+
+	encodings = nil
+	req.RetryUncompressed = true
+	assert.Nil(req.Do())
+	assert.Equal([]string{"gzip", ""}, encodings)
+	assert.Equal("hi", got.Variable)
+	assert.True(req.CompressRequest, "compression should remain enabled for later requests")
+}
+
+func TestProxyAuth(t *testing.T) {
+	assert := assert.New(t)
+	var proxied string
+	var proxyAuth string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		proxyAuth = r.Header.Get("Proxy-Authorization")
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer proxy.Close()
+
+	basic := func(user, pass string) string {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.SetBasicAuth(user, pass)
+		return r.Header.Get("Authorization")
+	}
+
+	req := NewRequest("GET", "http://api.example/things/1", *auth)
+	req.Proxy = strings.Replace(proxy.URL, "http://", "http://puser:ppass@", 1)
+	assert.Nil(req.Do())
+	assert.Equal("http://api.example/things/1", proxied)
+	assert.Equal(basic("puser", "ppass"), proxyAuth)
+
+	req.ProxyAuth = Auth{"other", "secret"}
+	assert.Nil(req.Do())
+	assert.Equal(basic("other", "secret"), proxyAuth)
+
+	req.Proxy = "not a url"
+	assert.NotNil(req.Do())
+}
@@ -0,0 +1,300 @@
+package restclient
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// defaultRetryStatusCodes are retried for idempotent methods when
+// neither RetryStatusCodes nor RetryOn is set
+var defaultRetryStatusCodes = []int{
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// defaultRetryBackoff is the delay before the first retry when
+// RetryBackoff is not set
+const defaultRetryBackoff = 100 * time.Millisecond
+
+// defaultMaxRetryDelay is the longest delay before a retry when
+// MaxRetryDelay is not set
+const defaultMaxRetryDelay = 30 * time.Second
+
+// send sends the request, retrying responses selected by
+// shouldRetry up to MaxRetries times.  The last response is
+// left in the Response field.  Independently of MaxRetries, an
+// idempotent request failing with a connection reset is retried once.
+// Attempts exceeding the AttemptTimeout before the response headers
+// arrive are retried, up to MaxRetries times, as for 502, 503 and 504
+// responses; the response body is read after send returns.
+func (r *Request) send() error {
+	var resetRetried bool
+	for attempt := 0; ; attempt++ {
+		resp, err := r.sendAttempt()
+		if err != nil && !resetRetried && r.canRetryReset(err) {
+			// A stale pooled connection; retry once on a fresh one
+			r.logger().Println("Retrying after connection reset:", err)
+			r.emitMetric(MetricRetry, err)
+			resetRetried = true
+			if r.Request.GetBody != nil {
+				if r.Request.Body, err = r.Request.GetBody(); err != nil {
+					return err
+				}
+			}
+			r.stats.Retries++
+			resp, err = r.sendAttempt()
+		}
+
+		var delay time.Duration
+		if err != nil {
+			if attempt >= r.MaxRetries || !r.canRetryAttemptTimeout(err) {
+				return err
+			}
+			delay = r.retryDelay(attempt, nil)
+			r.logger().Printf("Retrying after attempt timeout in %v (attempt %d of %d)\n", delay, attempt+1, r.MaxRetries)
+			r.emitMetric(MetricRetry, err)
+		} else {
+			r.Response = resp
+			r.emitMetric(MetricResponse, nil)
+			if attempt >= r.MaxRetries || !r.shouldRetry(resp) {
+				return nil
+			}
+			if r.Request.Body != nil && r.Request.GetBody == nil {
+				r.logger().Println("Cannot retry: request body cannot be rewound")
+				return nil
+			}
+
+			delay = r.retryDelay(attempt, resp)
+			r.logger().Printf("Retrying after status %d in %v (attempt %d of %d)\n", resp.StatusCode, delay, attempt+1, r.MaxRetries)
+			r.emitMetric(MetricRetry, nil)
+
+			// Discard the failed response
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-r.context().Done():
+			timer.Stop()
+			return r.context().Err()
+		}
+
+		// Rewind the body for the next attempt
+		if r.Request.GetBody != nil {
+			r.Request.Body, err = r.Request.GetBody()
+			if err != nil {
+				return err
+			}
+		}
+		r.stats.Retries++
+	}
+}
+
+// sendAttempt sends the request once, bounded by the AttemptTimeout,
+// if set.  The attempt's deadline also covers reading the response
+// body, so its context is only released once the body is closed.
+func (r *Request) sendAttempt() (*http.Response, error) {
+	if r.AttemptTimeout <= 0 {
+		return r.Client.Do(r.Request)
+	}
+	ctx, cancel := context.WithTimeout(r.Request.Context(), r.AttemptTimeout)
+	resp, err := r.Client.Do(r.Request.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if conn, ok := resp.Body.(io.ReadWriteCloser); ok {
+		// Keep an upgraded connection writable for the caller
+		resp.Body = cancelConn{conn, cancel}
+	} else {
+		resp.Body = cancelBody{resp.Body, cancel}
+	}
+	return resp, nil
+}
+
+// cancelBody releases the context of an attempt when its response
+// body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// cancelConn is a cancelBody for the connection of a 101 Switching
+// Protocols response
+type cancelConn struct {
+	io.ReadWriteCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelConn) Close() error {
+	err := c.ReadWriteCloser.Close()
+	c.cancel()
+	return err
+}
+
+// canRetryAttemptTimeout reports whether the error is the expiry of
+// the AttemptTimeout (rather than of the Request's Context) for a
+// request which may be retried: one with an idempotent method or an
+// IdempotencyKey, and a rewindable body
+func (r *Request) canRetryAttemptTimeout(err error) bool {
+	if r.AttemptTimeout <= 0 || !errors.Is(err, context.DeadlineExceeded) || r.context().Err() != nil {
+		return false
+	}
+	if !isIdempotent(r.Method) && r.IdempotencyKey == "" {
+		return false
+	}
+	return r.Request.Body == nil || r.Request.GetBody != nil
+}
+
+// canRetryReset reports whether the transport error is a connection
+// reset (or unexpected EOF) which may be retried once: the method must
+// be idempotent, the body rewindable and the context still live
+func (r *Request) canRetryReset(err error) bool {
+	if !isIdempotent(r.Method) || r.context().Err() != nil {
+		return false
+	}
+	if r.Request.Body != nil && r.Request.GetBody == nil {
+		return false
+	}
+	return isConnectionReset(err)
+}
+
+// isConnectionReset reports whether the error indicates the server
+// closed the connection without responding, as happens when a pooled
+// keep-alive connection has gone stale
+func isConnectionReset(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// shouldRetry reports whether the response should be retried.
+// RetryOn takes precedence over RetryStatusCodes; if neither is
+// set, 502, 503 and 504 responses to idempotent methods (or to
+// requests with an IdempotencyKey) are retried.
+func (r *Request) shouldRetry(resp *http.Response) bool {
+	if r.RetryOn != nil {
+		return r.RetryOn(resp)
+	}
+	codes := r.RetryStatusCodes
+	if codes == nil {
+		if !isIdempotent(r.Method) && r.IdempotencyKey == "" {
+			return false
+		}
+		codes = defaultRetryStatusCodes
+	}
+	for _, code := range codes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay returns the delay before the given retry attempt,
+// honoring a Retry-After header of the response (if any) and
+// otherwise backing off exponentially from RetryBackoff, limited to
+// the MaxRetryDelay
+func (r *Request) retryDelay(attempt int, resp *http.Response) time.Duration {
+	var d time.Duration
+	var ok bool
+	if resp != nil {
+		d, ok = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	if !ok {
+		backoff := r.RetryBackoff
+		if backoff == 0 {
+			backoff = defaultRetryBackoff
+		}
+		d = backoff << uint(attempt)
+	}
+
+	limit := r.MaxRetryDelay
+	if limit == 0 {
+		limit = defaultMaxRetryDelay
+	}
+	if limit > 0 && (d > limit || d < 0) {
+		r.logger().Printf("Limiting retry delay of %v to %v\n", d, limit)
+		return limit
+	}
+	return d
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which
+// is either a number of seconds or an HTTP date
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// idempotencyKey returns the Idempotency-Key to send, generating
+// one for a non-idempotent request which may be retried.  The key is
+// set on the http.Request once, so every attempt carries the same key.
+func (r *Request) idempotencyKey() string {
+	if r.IdempotencyKey != "" {
+		return r.IdempotencyKey
+	}
+	if r.MaxRetries == 0 || isIdempotent(r.Method) {
+		return ""
+	}
+	if key := r.Request.Header.Get("Idempotency-Key"); key != "" {
+		return key
+	}
+	key, err := newUUID()
+	if err != nil {
+		r.logger().Println("Failed to generate idempotency key:", err)
+		return ""
+	}
+	return key
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// isIdempotent reports whether the method is idempotent (RFC 7231
+// section 4.2.2), and so is safe to retry
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
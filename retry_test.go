@@ -0,0 +1,222 @@
+package restclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// statusSequence serves the given status codes in turn, then 200
+func statusSequence(codes ...int) (*httptest.Server, *int) {
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= len(codes) {
+			w.WriteHeader(codes[calls-1])
+			return
+		}
+		w.Write([]byte(`{"id":1}`))
+	}))
+	return ts, &calls
+}
+
+func TestRetryDefaults(t *testing.T) {
+	assert := assert.New(t)
+	ts, calls := statusSequence(503, 502)
+	defer ts.Close()
+
+	req := NewRequest("GET", ts.URL, *auth)
+	req.MaxRetries = 3
+	req.RetryBackoff = time.Millisecond
+	ret := new(TestThing)
+	req.ResponseBody = ret
+	assert.Nil(req.Do())
+	assert.Equal(3, *calls)
+	assert.Equal(2, req.Stats().Retries)
+	assert.Equal(1, ret.ID)
+
+	// POST is not idempotent, so is not retried by default
+	ts2, calls2 := statusSequence(503)
+	defer ts2.Close()
+	req = NewRequest("POST", ts2.URL, *auth)
+	req.MaxRetries = 3
+	req.RetryBackoff = time.Millisecond
+	req.RequestBody = TestStructRequest{"hi"}
+	assert.NotNil(req.Do())
+	assert.Equal(1, *calls2)
+}
+
+func TestRetryStatusCodes(t *testing.T) {
+	assert := assert.New(t)
+	ts, calls := statusSequence(429, 408, 500)
+	defer ts.Close()
+
+	req := NewRequest("POST", ts.URL, *auth)
+	req.MaxRetries = 5
+	req.RetryBackoff = time.Millisecond
+	req.RetryStatusCodes = []int{408, 429}
+	req.RequestBody = TestStructRequest{"hi"}
+	err := req.Do()
+	assert.NotNil(err)
+	assert.Equal(500, err.Code(), "500 is not in RetryStatusCodes")
+	assert.Equal(3, *calls)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	d, ok := parseRetryAfter("2", now)
+	assert.True(ok)
+	assert.Equal(2*time.Second, d)
+	d, ok = parseRetryAfter(now.Add(5*time.Second).Format(http.TimeFormat), now)
+	assert.True(ok)
+	assert.Equal(5*time.Second, d)
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(ok)
+}
+
+func TestMaxRetryDelay(t *testing.T) {
+	assert := assert.New(t)
+	req := NewRequest("GET", "http://url.com", *auth)
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", "86400")
+	assert.Equal(30*time.Second, req.retryDelay(0, resp), "a day-long Retry-After should be capped by default")
+
+	req.MaxRetryDelay = time.Second
+	assert.Equal(time.Second, req.retryDelay(0, resp))
+	assert.Equal(time.Second, req.retryDelay(20, nil), "backoff should be capped too")
+	assert.Equal(100*time.Millisecond, req.retryDelay(0, nil))
+
+	req.MaxRetryDelay = -1
+	assert.Equal(24*time.Hour, req.retryDelay(0, resp))
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	assert := assert.New(t)
+	var keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	req := NewRequest("POST", ts.URL, *auth)
+	req.MaxRetries = 1
+	req.RetryBackoff = time.Millisecond
+	req.RetryStatusCodes = []int{http.StatusServiceUnavailable}
+	req.RequestBody = TestStructRequest{"hi"}
+	assert.Nil(req.Do())
+	assert.Equal(2, len(keys))
+	assert.Len(keys[0], 36, "a UUID key should be generated")
+	assert.Equal(keys[0], keys[1], "retries should carry the same key")
+
+	// An explicit key makes the POST retryable by default
+	keys = nil
+	req.RetryStatusCodes = nil
+	req.IdempotencyKey = "abc"
+	assert.Nil(req.Do())
+	assert.Equal([]string{"abc", "abc"}, keys)
+}
+
+func TestRetryConnectionReset(t *testing.T) {
+	assert := assert.New(t)
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// Drop the connection without responding
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer ts.Close()
+
+	req := NewRequest("GET", ts.URL, *auth)
+	ret := new(TestThing)
+	req.ResponseBody = ret
+	assert.Nil(req.Do())
+	assert.Equal(1, ret.ID)
+	assert.EqualValues(2, atomic.LoadInt32(&calls))
+	assert.Equal(1, req.Stats().Retries)
+
+	// Non-idempotent requests are not retried
+	atomic.StoreInt32(&calls, 0)
+	req = NewRequest("POST", ts.URL, *auth)
+	req.RequestBody = TestStructRequest{"hi"}
+	assert.NotNil(req.Do())
+	assert.EqualValues(1, atomic.LoadInt32(&calls))
+}
+
+func TestAttemptTimeout(t *testing.T) {
+	assert := assert.New(t)
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer ts.Close()
+
+	// The slow first attempt is abandoned and retried
+	req := NewRequest("GET", ts.URL, *auth)
+	req.AttemptTimeout = 50 * time.Millisecond
+	req.MaxRetries = 1
+	req.RetryBackoff = time.Millisecond
+	ret := new(TestThing)
+	req.ResponseBody = ret
+	assert.Nil(req.Do())
+	assert.Equal(1, ret.ID)
+	assert.EqualValues(2, atomic.LoadInt32(&calls))
+	assert.Equal(1, req.Stats().Retries)
+
+	// Without retries, the attempt times out
+	atomic.StoreInt32(&calls, 0)
+	req = NewRequest("GET", ts.URL, *auth)
+	req.AttemptTimeout = 50 * time.Millisecond
+	err := req.Do()
+	_, ok := err.(TimeoutError)
+	assert.True(ok, "an attempt timeout should produce a TimeoutError")
+
+	// The overall deadline ends the call without further retries
+	atomic.StoreInt32(&calls, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req = NewRequest("GET", ts.URL, *auth)
+	req.Context = ctx
+	req.AttemptTimeout = time.Second
+	req.MaxRetries = 3
+	err = req.Do()
+	_, ok = err.(TimeoutError)
+	assert.True(ok)
+	assert.EqualValues(1, atomic.LoadInt32(&calls))
+}
+
+// An attempt timing out while its body is read fails with a TimeoutError
+func TestAttemptTimeoutBody(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":`))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	req := NewRequest("GET", ts.URL, *auth)
+	req.AttemptTimeout = 50 * time.Millisecond
+	req.ResponseBody = new(TestThing)
+	err := req.Do()
+	_, ok := err.(TimeoutError)
+	assert.True(ok, "a timeout reading the body should produce a TimeoutError, not %T: %v", err, err)
+}
@@ -0,0 +1,92 @@
+package restclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// rpcID is the ID of the last JSON-RPC request
+var rpcID int64
+
+// rpcRequest is a JSON-RPC 2.0 request envelope
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+	ID      int64       `json:"id"`
+}
+
+// rpcResponse is a JSON-RPC 2.0 response envelope
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	} `json:"error"`
+	ID json.RawMessage `json:"id"`
+}
+
+// RPCError is returned when a JSON-RPC call returns an error object
+type RPCError struct {
+	BaseError
+	RPCCode    int             // The code of the error object
+	RPCMessage string          // The message of the error object
+	Data       json.RawMessage // The data of the error object, if any
+}
+
+// CallRPC makes a JSON-RPC 2.0 call of the method with the given
+// params (which may be nil) to the url, decoding the result into
+// result (which may be nil).  An error object in the response is
+// returned as an RPCError.
+func CallRPC(url string, auth Auth, method string, params interface{}, result interface{}) Error {
+	r := NewRequest("POST", url, auth)
+	return r.callRPC(method, params, result)
+}
+
+// CallRPC makes a JSON-RPC 2.0 call to the given path; see CallRPC
+func (c *Client) CallRPC(path string, method string, params interface{}, result interface{}) Error {
+	r := c.NewRequest("POST", path)
+	return r.callRPC(method, params, result)
+}
+
+// callRPC sends the JSON-RPC envelope for the call and unwraps the
+// response
+func (r *Request) callRPC(method string, params interface{}, result interface{}) Error {
+	r.logger().Println("callRPC: started", method)
+	resp := new(rpcResponse)
+	r.RequestType = "json"
+	r.RequestBody = rpcRequest{"2.0", method, params, atomic.AddInt64(&rpcID, 1)}
+	r.ResponseBody = resp
+	// Servers may send the error object with a 4xx or 5xx status
+	r.ErrorBody = resp
+	err := r.Do()
+	if resp.Error != nil {
+		return r.rpcError(method, resp)
+	}
+	if err != nil {
+		return err
+	}
+
+	if result != nil && len(resp.Result) > 0 {
+		if derr := r.decodeJson(resp.Result, result); derr != nil {
+			r.logger().Println("Failed to decode RPC result:", derr)
+			return BaseError{r.Response.StatusCode, "Decode Error", fmt.Errorf("Failed to decode result of %s: %v", method, derr)}
+		}
+	}
+	r.logger().Println("callRPC: completed")
+	return nil
+}
+
+// rpcError builds the RPCError for the error object of the response
+func (r *Request) rpcError(method string, resp *rpcResponse) Error {
+	r.logger().Println("RPC error:", resp.Error.Code, resp.Error.Message)
+	return RPCError{
+		BaseError{r.Response.StatusCode, "RPC Error", fmt.Errorf("RPC error %d calling %s: %s", resp.Error.Code, method, resp.Error.Message)},
+		resp.Error.Code,
+		resp.Error.Message,
+		resp.Error.Data,
+	}
+}
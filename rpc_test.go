@@ -0,0 +1,53 @@
+package restclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallRPC(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			JSONRPC string `json:"jsonrpc"`
+			Method  string `json:"method"`
+			Params  []int  `json:"params"`
+			ID      int    `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Method == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","error":{"code":-32603,"message":"Internal error"},"id":%d}`, req.ID)
+			return
+		}
+		if req.JSONRPC != "2.0" || req.Method != "sum" {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":%d}`, req.ID)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","result":%d,"id":%d}`, req.Params[0]+req.Params[1], req.ID)
+	}))
+	defer ts.Close()
+
+	var sum int
+	assert.Nil(CallRPC(ts.URL, *auth, "sum", []int{2, 3}, &sum))
+	assert.Equal(5, sum)
+
+	err := CallRPC(ts.URL, *auth, "product", []int{2, 3}, &sum)
+	rpcErr, ok := err.(RPCError)
+	assert.True(ok, "an error object should produce an RPCError")
+	assert.Equal(-32601, rpcErr.RPCCode)
+	assert.Equal("Method not found", rpcErr.RPCMessage)
+
+	// An error object sent with a 5xx status is still unwrapped
+	err = CallRPC(ts.URL, *auth, "fail", nil, &sum)
+	rpcErr, ok = err.(RPCError)
+	if assert.True(ok, "an error object should produce an RPCError whatever the status") {
+		assert.Equal(-32603, rpcErr.RPCCode)
+		assert.Equal(500, rpcErr.StatusCode)
+	}
+}
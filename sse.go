@@ -0,0 +1,146 @@
+package restclient
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultEventRetry is the delay before reconnecting to an event
+// stream when the server has not sent a retry hint
+const defaultEventRetry = 3 * time.Second
+
+// Event is a Server-Sent Event
+type Event struct {
+	ID    string        // Last event ID, carried over from earlier events if not set by this one
+	Event string        // Event type (defaults to "message")
+	Data  string        // Event data, with multiple data lines joined by newlines
+	Retry time.Duration // Reconnection delay requested by the server, if any
+}
+
+// eventStream holds the state of a subscription across reconnections
+type eventStream struct {
+	handler     func(Event) error
+	lastEventID string
+	retry       time.Duration
+	handlerErr  error
+}
+
+// Subscribe consumes a Server-Sent Events (text/event-stream)
+// response, calling handler with each event.  When the stream ends,
+// the request is resent after the delay given by the server's retry
+// hint, with a Last-Event-ID header so the server may resume the
+// stream.  Subscribe returns when the Request's Context is done (with
+// an error wrapping the context's error), when handler returns an
+// error, when the request fails, or when the server responds 204 No
+// Content to a reconnection.
+func (r *Request) Subscribe(handler func(Event) error) Error {
+	r.logger().Println("Subscribe: started")
+	s := &eventStream{handler: handler, retry: defaultEventRetry}
+	r.events = s
+	defer func() {
+		r.events = nil
+	}()
+	r.SetHeader("Accept", "text/event-stream")
+
+	for {
+		if s.lastEventID != "" {
+			r.SetHeader("Last-Event-ID", s.lastEventID)
+		}
+		// Judge the connection by this attempt's response alone
+		r.Response = nil
+		err := r.Do()
+		if cerr := r.context().Err(); cerr != nil {
+			r.logger().Println("Subscription stopped:", cerr)
+			return transportError(cerr)
+		}
+		if s.handlerErr != nil {
+			return BaseError{0, "Event Handler Error", s.handlerErr}
+		}
+		connected := r.Response != nil && r.Response.StatusCode >= 200 && r.Response.StatusCode < 300
+		if err != nil && !connected {
+			return err
+		}
+		if r.NoContent() {
+			r.logger().Println("Subscribe: completed")
+			return nil
+		}
+
+		// The stream ended or broke; reconnect after the retry delay
+		r.logger().Println("Event stream ended; reconnecting in", s.retry, err)
+		timer := time.NewTimer(s.retry)
+		select {
+		case <-timer.C:
+		case <-r.context().Done():
+			timer.Stop()
+			return transportError(r.context().Err())
+		}
+	}
+}
+
+// decodeEvents parses an event stream response body, passing each
+// event to the subscription's handler
+func (r *Request) decodeEvents() error {
+	r.logger().Println("Streaming event stream response")
+	s := r.events
+	body, err := r.streamBody()
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+
+	var e Event
+	var data []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			// A blank line dispatches the event
+			if data != nil {
+				e.ID = s.lastEventID
+				if e.Event == "" {
+					e.Event = "message"
+				}
+				e.Data = strings.Join(data, "\n")
+				if herr := s.handler(e); herr != nil {
+					s.handlerErr = herr
+					return herr
+				}
+			}
+			e = Event{}
+			data = nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// Comment
+			continue
+		}
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i != -1 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			e.Event = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.lastEventID = value
+			}
+		case "retry":
+			if ms, perr := strconv.Atoi(value); perr == nil && ms >= 0 {
+				e.Retry = time.Duration(ms) * time.Millisecond
+				s.retry = e.Retry
+			}
+		default:
+			r.logger().Println("Ignoring unknown event field:", field)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("Event stream broken: %v", err)
+	}
+	return nil
+}
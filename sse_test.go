@@ -0,0 +1,98 @@
+package restclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribe(t *testing.T) {
+	assert := assert.New(t)
+	var lastEventIDs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		if len(lastEventIDs) > 2 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": comment\nretry: 1\n\n")
+		fmt.Fprintf(w, "id: %d\nevent: update\ndata: line one\ndata: line two\n\n", len(lastEventIDs))
+		fmt.Fprint(w, "data: plain\r\n\r\n")
+	}))
+	defer ts.Close()
+
+	var events []Event
+	req := NewRequest("GET", ts.URL, *auth)
+	err := req.Subscribe(func(e Event) error {
+		events = append(events, e)
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal([]string{"", "1", "2"}, lastEventIDs, "reconnections should resume from the last event ID")
+	assert.Equal(4, len(events))
+	assert.Equal(Event{ID: "1", Event: "update", Data: "line one\nline two"}, events[0])
+	assert.Equal(Event{ID: "1", Event: "message", Data: "plain"}, events[1])
+}
+
+func TestSubscribeCanceled(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := NewRequest("GET", ts.URL, *auth)
+	req.Context = ctx
+	var got []string
+	err := req.Subscribe(func(e Event) error {
+		got = append(got, e.Data)
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return nil
+	})
+	assert.True(errors.Is(err, context.Canceled))
+	assert.Equal([]string{"first"}, got)
+
+	// A handler error stops the subscription
+	req.Context = nil
+	stop := errors.New("stop")
+	err = req.Subscribe(func(e Event) error { return stop })
+	assert.True(errors.Is(err, stop))
+}
+
+// A failed reconnection ends the subscription with its error
+func TestSubscribeReconnectFails(t *testing.T) {
+	assert := assert.New(t)
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls > 1 {
+			// The server has gone away
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "retry: 1\ndata: first\n\n")
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req := NewRequest("GET", ts.URL, *auth)
+	req.Context = ctx
+	err := req.Subscribe(func(e Event) error { return nil })
+	assert.NotNil(err)
+	assert.False(errors.Is(err, context.DeadlineExceeded), "the reconnection's error should be returned, not %v", err)
+	assert.Nil(ctx.Err(), "Subscribe should not wait for the deadline")
+}
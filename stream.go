@@ -0,0 +1,137 @@
+package restclient
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// maxStreamLine is the longest line accepted from a streamed response
+const maxStreamLine = 16 << 20
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+// BodyWriter returns a writer for streaming the request body of
+// indefinite length, replacing any RequestReader.  Data is sent
+// with chunked transfer encoding as it is written, without
+// buffering, until the writer is closed (or closed with an error,
+// aborting the request).  Do blocks until then, so the writes must
+// be made concurrently with it.  The RequestBody should be nil.
+func (r *Request) BodyWriter() *io.PipeWriter {
+	pr, pw := io.Pipe()
+	r.RequestReader = pr
+	r.ContentLength = 0
+	return pw
+}
+
+// streamBody returns the response body, counting bytes read into
+// the Request's Stats and undoing any Content-Encoding
+func (r *Request) streamBody() (io.Reader, error) {
+	return r.decodeResponseContent(countingReader{r.Response.Body, &r.stats.BytesRead})
+}
+
+// decodeNDJSON reads a newline-delimited JSON response body line by
+// line, passing each non-blank line to the LineHandler
+func (r *Request) decodeNDJSON() error {
+	r.logger().Println("Streaming NDJSON response")
+	body, err := r.streamBody()
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		// The scanner reuses its buffer, so hand off a copy
+		if err := r.LineHandler(json.RawMessage(append([]byte(nil), line...))); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// decodeJSONArray reads a JSON array response body one element at
+// a time, decoding each element into a value from NewElement and
+// passing it to the ElementHandler
+func (r *Request) decodeJSONArray() error {
+	r.logger().Println("Streaming JSON array response")
+	body, err := r.streamBody()
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(body)
+	if r.StrictDecode {
+		dec.DisallowUnknownFields()
+	}
+	if r.UseNumber {
+		dec.UseNumber()
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("Expected a JSON array, found %v", tok)
+	}
+	for dec.More() {
+		var elem interface{}
+		if r.NewElement != nil {
+			elem = r.NewElement()
+		} else {
+			elem = new(interface{})
+		}
+		if err = dec.Decode(elem); err != nil {
+			return err
+		}
+		if err = r.ElementHandler(elem); err != nil {
+			return err
+		}
+	}
+	// Consume the closing bracket
+	if _, err = dec.Token(); err != nil {
+		return err
+	}
+	// Read to the end of the body, so that trailers are available
+	_, err = io.Copy(ioutil.Discard, dec.Buffered())
+	if err == nil {
+		_, err = io.Copy(ioutil.Discard, body)
+	}
+	return err
+}
+
+// encodeNDJSON encodes each element of the request body (which must
+// be a slice or array) as a line of JSON
+func (r *Request) encodeNDJSON() ([]byte, error) {
+	r.logger().Printf("Encoding bodyObject (%+v) to ndjson", r.RequestBody)
+	var elems []json.RawMessage
+	b, err := json.Marshal(r.RequestBody)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &elems); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, e := range elems {
+		buf.Write(e)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,96 @@
+package restclient
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings records the phases of a traced request.  Phases which did
+// not occur (e.g. DNS and connect on a reused connection) are zero.
+type Timings struct {
+	DNS             time.Duration // Time spent resolving the host
+	Connect         time.Duration // Time spent establishing the TCP connection
+	TLSHandshake    time.Duration // Time spent on the TLS handshake
+	TimeToFirstByte time.Duration // Time from obtaining a connection to the first response byte
+	ConnReused      bool          // Whether a pooled connection was reused
+}
+
+// timingsTrace accumulates Timings from httptrace callbacks, which
+// may be invoked from other goroutines
+type timingsTrace struct {
+	mu sync.Mutex
+	t  Timings
+
+	dnsStart, connectStart, tlsStart, gotConn time.Time
+}
+
+func (tt *timingsTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			tt.mu.Lock()
+			tt.dnsStart = time.Now()
+			tt.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			tt.mu.Lock()
+			tt.t.DNS = time.Since(tt.dnsStart)
+			tt.mu.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			tt.mu.Lock()
+			tt.connectStart = time.Now()
+			tt.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			tt.mu.Lock()
+			tt.t.Connect = time.Since(tt.connectStart)
+			tt.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			tt.mu.Lock()
+			tt.tlsStart = time.Now()
+			tt.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			tt.mu.Lock()
+			tt.t.TLSHandshake = time.Since(tt.tlsStart)
+			tt.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			tt.mu.Lock()
+			tt.gotConn = time.Now()
+			tt.t.ConnReused = info.Reused
+			tt.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			tt.mu.Lock()
+			tt.t.TimeToFirstByte = time.Since(tt.gotConn)
+			tt.mu.Unlock()
+		},
+	}
+}
+
+// timings returns a snapshot of the recorded Timings
+func (tt *timingsTrace) timings() Timings {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	return tt.t
+}
+
+// installTrace attaches a fresh timing trace to the http.Request
+func (r *Request) installTrace() {
+	r.trace = new(timingsTrace)
+	ctx := httptrace.WithClientTrace(r.Request.Context(), r.trace.clientTrace())
+	r.Request = r.Request.WithContext(ctx)
+}
+
+// Timings returns the phase timings of the most recent execution of
+// the Request.  They are only recorded when TraceTimings is set.
+func (r *Request) Timings() Timings {
+	if r.trace == nil {
+		return Timings{}
+	}
+	return r.trace.timings()
+}